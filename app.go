@@ -4,9 +4,17 @@ import (
 	"database/sql"
 
 	"github.com/James-Wolfley/steam-achievement-tracker/db"
+	"github.com/James-Wolfley/steam-achievement-tracker/icons"
+	"github.com/James-Wolfley/steam-achievement-tracker/jobs"
+	"github.com/James-Wolfley/steam-achievement-tracker/scheduler"
+	"github.com/James-Wolfley/steam-achievement-tracker/steamapi"
 )
 
 type Application struct {
-	DB   *sql.DB
-	Repo db.Repo
+	DB        *sql.DB
+	Repo      db.Repo
+	Icons     *icons.Fetcher
+	Scheduler *scheduler.Scheduler
+	Jobs      *jobs.Manager
+	Steam     *steamapi.Client // nil without STEAM_API_KEY; resolves custom URL names
 }
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/config"
+	dbpkg "github.com/James-Wolfley/steam-achievement-tracker/db"
+)
+
+// css/output.css is generated by tailwind, so build it before `go build`.
+//
+//go:embed css scripts
+var embeddedAssets embed.FS
+
+// assetFS returns the static files (css/, scripts/) and migrations to use:
+// the embedded copies, or the ones under config.AssetsDir in dev builds.
+func assetFS() (static fs.FS, migrations fs.FS) {
+	if dir := config.AssetsDir(); dir != "" {
+		return os.DirFS(dir), os.DirFS(filepath.Join(dir, "db", "migrations"))
+	}
+	return embeddedAssets, dbpkg.Migrations()
+}
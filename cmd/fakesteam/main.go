@@ -0,0 +1,33 @@
+// Command fakesteam serves Steam Web API fixtures for offline development.
+//
+//	go run ./cmd/fakesteam -fixtures testdata/fakesteam -scenario testdata/fakesteam/scenarios/dlc.json
+//	STEAM_API_BASE_URL=http://localhost:8090 go run -tags=dev .
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/steamapi/fakesteam"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	fixtures := flag.String("fixtures", "testdata/fakesteam", "fixture directory")
+	scenario := flag.String("scenario", "", "optional scenario JSON file")
+	flag.Parse()
+
+	srv, err := fakesteam.New(*fixtures)
+	if err != nil {
+		log.Fatalf("fakesteam: %v", err)
+	}
+	if *scenario != "" {
+		if err := srv.LoadScenario(*scenario); err != nil {
+			log.Fatalf("fakesteam: %v", err)
+		}
+	}
+
+	log.Printf("fakesteam listening on %s (fixtures: %s)", *addr, *fixtures)
+	log.Fatal(http.ListenAndServe(*addr, srv))
+}
@@ -0,0 +1,114 @@
+// Command migrate inspects and changes the schema version of the app database.
+//
+//	go run ./cmd/migrate status
+//	go run ./cmd/migrate -dry-run up
+//	go run ./cmd/migrate -steps 2 down
+//
+// The app applies pending migrations on start; this tool is for checking what
+// has run and for rolling back.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/db"
+)
+
+func main() {
+	dbPath := flag.String("db", "data/app.db", "SQLite database file")
+	dir := flag.String("dir", "", "read migrations from this directory instead of the embedded copies")
+	steps := flag.Int("steps", 1, "migrations to revert with down")
+	dryRun := flag.Bool("dry-run", false, "print what up/down would do without changing anything")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: migrate [flags] status|up|down\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	sqlDB, err := db.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("open db: %v", err)
+	}
+	defer sqlDB.Close()
+	ctx := context.Background()
+
+	migrations := db.Migrations()
+	if *dir != "" {
+		migrations = os.DirFS(*dir)
+	}
+
+	switch flag.Arg(0) {
+	case "status":
+		ms, err := db.MigrationStatus(ctx, sqlDB, migrations)
+		if err != nil {
+			log.Fatalf("status: %v", err)
+		}
+		printStatus(ms)
+	case "up":
+		ran, err := db.MigrateUp(ctx, sqlDB, migrations, *dryRun)
+		report("apply", "applied", ran, *dryRun)
+		if err != nil {
+			log.Fatalf("up: %v", err)
+		}
+	case "down":
+		reverted, err := db.MigrateDown(ctx, sqlDB, migrations, *steps, *dryRun)
+		report("revert", "reverted", reverted, *dryRun)
+		if err != nil {
+			log.Fatalf("down: %v", err)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// report prints one line per version, e.g. "applied 007_x.sql" or "would apply 007_x.sql".
+func report(verb, past string, versions []string, dryRun bool) {
+	if len(versions) == 0 {
+		fmt.Printf("nothing to %s\n", verb)
+		return
+	}
+	prefix := past
+	if dryRun {
+		prefix = "would " + verb
+	}
+	for _, v := range versions {
+		fmt.Printf("%s %s\n", prefix, v)
+	}
+}
+
+func printStatus(ms []db.Migration) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tSTATE\tAPPLIED AT\tDOWN")
+	for _, m := range ms {
+		state := "pending"
+		switch {
+		case m.Missing:
+			state = "applied (file missing)"
+		case m.Modified:
+			state = "applied (MODIFIED)"
+		case m.Applied:
+			state = "applied"
+		}
+		at := "-"
+		if m.AppliedAt != nil {
+			at = m.AppliedAt.Local().Format(time.DateTime)
+		}
+		down := "no"
+		if m.HasDown {
+			down = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.Version, state, at, down)
+	}
+	_ = w.Flush()
+}
@@ -8,12 +8,19 @@ import (
 )
 
 type Row struct {
-	SteamID string
-	AppID   int64
+	SteamID  string
+	AppID    int64
+	GameName string // "" if the game isn't in the games table
+	GameIcon string // "" if unknown or not cached locally yet
+
+	// Snapshots compared (PrevSnapshotID is 0 if there is no previous)
+	PrevSnapshotID int64
+	CurrSnapshotID int64
 
 	// Snapshot times
-	PrevTakenAt *time.Time // nil if no previous
-	CurrTakenAt time.Time
+	PrevTakenAt   *time.Time // nil if no previous
+	CurrTakenAt   time.Time
+	PrevSynthetic bool // previous snapshot was backfilled from unlock times
 
 	// Counts
 	PrevDone, PrevTotal   int
@@ -31,33 +38,73 @@ type Row struct {
 	Regression   bool // was 100%, now total>done
 
 	// Diff lists (can be empty)
-	Added       []string // new cheevos added to catalog
-	Removed     []string // cheevos removed from catalog
-	NewlyEarned []string // 0->1
-	Lost        []string // 1->0 (rare)
+	Added       []Achievement // new cheevos added to catalog
+	Removed     []Achievement // cheevos removed from catalog
+	NewlyEarned []Achievement // 0->1, with Steam's unlock time when known
+	Lost        []Achievement // 1->0 (rare)
+
+	// Rarity highlights in the current snapshot (nil if no rarity data)
+	RarestUnlocked  *Achievement
+	RarestRemaining *Achievement
+}
+
+// HiddenDescr replaces the description of a hidden achievement the player hasn't unlocked.
+const HiddenDescr = "Hidden achievement. Details are revealed once unlocked."
+
+// Achievement is a single achievement as reported in a comparison.
+type Achievement struct {
+	APIName    string
+	Name       string     // display name ("" if not in catalog)
+	Descr      string     // HiddenDescr when Masked
+	Icon       string     // icon URL matching Achieved (gray while locked)
+	Hidden     bool       // Steam marks it hidden
+	Masked     bool       // description withheld as a spoiler
+	Achieved   bool       // unlocked in the current snapshot
+	GlobalPct  *float64   // % of all players who unlocked it; nil if unknown
+	UnlockedAt *time.Time // nil if Steam didn't report one
+}
+
+// Extras carries optional per-game data used to enrich a Row. Zero value is fine.
+type Extras struct {
+	Catalog     map[string]db.AchievementDef // by apiname
+	UnlockTimes map[string]time.Time         // by apiname
+	Current     []db.SnapshotAchievement     // current snapshot rows (for rarest unlocked/remaining)
+	ShowHidden  bool                         // reveal descriptions of locked hidden achievements
+	GameName    string
+	GameIcon    string
 }
 
 // BuildRow assembles a comparison row from prev (optional), curr (required),
-// and the per-snapshot achievement diffs (prev vs curr).
-func BuildRow(prev *db.Snapshot, curr db.Snapshot, diff db.AchievementDiff) Row {
+// the per-snapshot achievement diffs (prev vs curr), and optional extras.
+func BuildRow(prev *db.Snapshot, curr db.Snapshot, diff db.AchievementDiff, ex Extras) Row {
 	var r Row
 	r.SteamID = curr.SteamID
 	r.AppID = curr.AppID
+	r.GameName = ex.GameName
+	r.GameIcon = ex.GameIcon
 	r.CurrDone = curr.TotalDone
 	r.CurrTotal = curr.TotalAvailable
 	r.CurrTakenAt = curr.TakenAt
+	r.CurrSnapshotID = curr.ID
 	r.CurrPct = pct(curr.TotalDone, curr.TotalAvailable)
 
 	// Diff lists
-	r.Added = diff.Added
-	r.Removed = diff.Removed
-	r.NewlyEarned = diff.NewlyEarned
-	r.Lost = diff.Lost
+	achieved := make(map[string]bool, len(ex.Current))
+	for _, sa := range ex.Current {
+		achieved[sa.APIName] = sa.Achieved
+	}
+	r.Added = ex.achievements(diff.Added, achieved)
+	r.Removed = ex.achievements(diff.Removed, achieved)
+	r.NewlyEarned = ex.achievements(diff.NewlyEarned, achieved)
+	r.Lost = ex.achievements(diff.Lost, achieved)
+	r.RarestUnlocked, r.RarestRemaining = ex.rarest()
 
 	if prev != nil {
 		r.PrevDone = prev.TotalDone
 		r.PrevTotal = prev.TotalAvailable
 		r.PrevTakenAt = &prev.TakenAt
+		r.PrevSnapshotID = prev.ID
+		r.PrevSynthetic = prev.Synthetic
 		r.PrevPct = pct(prev.TotalDone, prev.TotalAvailable)
 
 		r.DeltaDone = r.CurrDone - r.PrevDone
@@ -84,6 +131,64 @@ func BuildRow(prev *db.Snapshot, curr db.Snapshot, diff db.AchievementDiff) Row
 	return r
 }
 
+// Achievement builds the display entry for api from whatever extras are known.
+// Locked hidden achievements have their description masked unless ex.ShowHidden.
+func (ex Extras) Achievement(api string, achieved bool) Achievement {
+	a := Achievement{APIName: api, Achieved: achieved}
+	if d, ok := ex.Catalog[api]; ok {
+		a.Name = d.Name
+		a.Descr = d.Descr
+		a.Icon = d.IconGray
+		if achieved || a.Icon == "" {
+			a.Icon = d.Icon
+		}
+		a.Hidden = d.Hidden
+		a.GlobalPct = d.GlobalPct
+		if d.Hidden && !achieved && !ex.ShowHidden {
+			a.Descr = HiddenDescr
+			a.Masked = true
+		}
+	}
+	if t, ok := ex.UnlockTimes[api]; ok {
+		a.UnlockedAt = &t
+	}
+	return a
+}
+
+// achievements maps apinames to display entries; achieved is the current state.
+func (ex Extras) achievements(apis []string, achieved map[string]bool) []Achievement {
+	if len(apis) == 0 {
+		return nil
+	}
+	out := make([]Achievement, 0, len(apis))
+	for _, api := range apis {
+		out = append(out, ex.Achievement(api, achieved[api]))
+	}
+	return out
+}
+
+// rarest returns the lowest-percentage achievement the player has and the lowest one
+// still locked, among those with known rarity.
+func (ex Extras) rarest() (unlocked, remaining *Achievement) {
+	var minU, minR float64
+	for _, sa := range ex.Current {
+		d, ok := ex.Catalog[sa.APIName]
+		if !ok || d.GlobalPct == nil {
+			continue
+		}
+		p := *d.GlobalPct
+		if sa.Achieved && (unlocked == nil || p < minU) {
+			a := ex.Achievement(sa.APIName, true)
+			unlocked, minU = &a, p
+		}
+		if !sa.Achieved && (remaining == nil || p < minR) {
+			a := ex.Achievement(sa.APIName, false)
+			remaining, minR = &a, p
+		}
+	}
+	return unlocked, remaining
+}
+
 func pct(done, total int) float64 {
 	if total <= 0 {
 		return 0
@@ -100,10 +205,17 @@ func CSVHeader() []string {
 		"delta_done", "delta_total", "delta_pct",
 		"completed_now", "was_completed", "regression", "new_content",
 		"added", "removed", "newly_earned", "lost",
+		"newly_earned_at", "prev_synthetic",
+		"newly_earned_pct",
+		"rarest_unlocked", "rarest_unlocked_pct",
+		"rarest_remaining", "rarest_remaining_pct",
+		"prev_snapshot_id", "curr_snapshot_id",
+		"game_name",
 	}
 }
 
-// ToCSV flattens the Row for CSV export. Lists are comma-separated.
+// ToCSV flattens the Row for CSV export. Achievements are listed by display name;
+// lists are "; "-separated since names may contain commas.
 func (r Row) ToCSV() []string {
 	prevAt := ""
 	if r.PrevTakenAt != nil {
@@ -127,13 +239,75 @@ func (r Row) ToCSV() []string {
 		boolStr(r.WasCompleted),
 		boolStr(r.Regression),
 		boolStr(r.NewContent),
-		strJoin(r.Added),
-		strJoin(r.Removed),
-		strJoin(r.NewlyEarned),
-		strJoin(r.Lost),
+		strJoin(namesCSV(r.Added)),
+		strJoin(namesCSV(r.Removed)),
+		strJoin(namesCSV(r.NewlyEarned)),
+		strJoin(namesCSV(r.Lost)),
+		strJoin(unlockTimesCSV(r.NewlyEarned)),
+		boolStr(r.PrevSynthetic),
+		strJoin(globalPctCSV(r.NewlyEarned)),
+		rareName(r.RarestUnlocked),
+		rarePct(r.RarestUnlocked),
+		rareName(r.RarestRemaining),
+		rarePct(r.RarestRemaining),
+		fmt.Sprintf("%d", r.PrevSnapshotID),
+		fmt.Sprintf("%d", r.CurrSnapshotID),
+		r.GameName,
 	}
 }
 
+// DisplayName is the catalog name, falling back to the apiname.
+func (a Achievement) DisplayName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.APIName
+}
+
+func namesCSV(xs []Achievement) []string {
+	out := make([]string, 0, len(xs))
+	for _, a := range xs {
+		out = append(out, a.DisplayName())
+	}
+	return out
+}
+
+// globalPctCSV formats global percentages ("" if unknown) in list order.
+func globalPctCSV(xs []Achievement) []string {
+	out := make([]string, 0, len(xs))
+	for _, a := range xs {
+		out = append(out, rarePct(&a))
+	}
+	return out
+}
+
+func rareName(a *Achievement) string {
+	if a == nil {
+		return ""
+	}
+	return a.DisplayName()
+}
+
+func rarePct(a *Achievement) string {
+	if a == nil || a.GlobalPct == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", *a.GlobalPct)
+}
+
+// unlockTimesCSV formats unlock times (RFC3339, "" if unknown) in list order.
+func unlockTimesCSV(xs []Achievement) []string {
+	out := make([]string, 0, len(xs))
+	for _, a := range xs {
+		if a.UnlockedAt == nil {
+			out = append(out, "")
+			continue
+		}
+		out = append(out, a.UnlockedAt.UTC().Format(time.RFC3339))
+	}
+	return out
+}
+
 func boolStr(b bool) string {
 	if b {
 		return "true"
@@ -145,10 +319,10 @@ func strJoin(xs []string) string {
 	if len(xs) == 0 {
 		return ""
 	}
-	// Comma-separated with no spaces (CSV writers will quote as needed)
+	// "; "-separated (encoding/csv quotes the field as needed)
 	out := xs[0]
 	for i := 1; i < len(xs); i++ {
-		out += "," + xs[i]
+		out += "; " + xs[i]
 	}
 	return out
 }
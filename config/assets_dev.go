@@ -0,0 +1,12 @@
+//go:build dev
+
+package config
+
+import "os"
+
+// AssetsDir is the checkout to read css, scripts and db/migrations from instead
+// of the copies embedded in the binary, so they can be edited without a rebuild.
+// Dev only: set ASSETS_DIR (e.g. "."); empty means embedded.
+func AssetsDir() string {
+	return os.Getenv("ASSETS_DIR")
+}
@@ -0,0 +1,9 @@
+//go:build !dev
+
+package config
+
+// AssetsDir is always empty in prod: css, scripts and migrations are served
+// from the copies embedded in the binary. Dev builds honor ASSETS_DIR.
+func AssetsDir() string {
+	return ""
+}
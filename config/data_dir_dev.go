@@ -0,0 +1,17 @@
+//go:build dev
+
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// Dev default: data/ in the working directory, since `go run` builds the
+// executable into a temporary directory. Still overrideable via DATA_DIR.
+func DataDir() string {
+	if v := strings.TrimSpace(os.Getenv("DATA_DIR")); v != "" {
+		return v
+	}
+	return "data"
+}
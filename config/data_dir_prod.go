@@ -0,0 +1,26 @@
+//go:build !dev
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DataDir is where the database (app.db) and the icon cache (images/) live.
+// Prod default: data/ next to the executable, whatever directory it is started
+// from. Override with DATA_DIR.
+func DataDir() string {
+	if v := strings.TrimSpace(os.Getenv("DATA_DIR")); v != "" {
+		return v
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "data"
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return filepath.Join(filepath.Dir(exe), "data")
+}
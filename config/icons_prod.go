@@ -0,0 +1,20 @@
+//go:build !dev
+
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// IconSyncInterval is how often the icon cache looks for new or orphaned icons.
+// Prod default: 10m. Override with ICON_SYNC_SECONDS (0 disables the background sync).
+func IconSyncInterval() time.Duration {
+	if v := os.Getenv("ICON_SYNC_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return 10 * time.Minute
+}
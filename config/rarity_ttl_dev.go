@@ -0,0 +1,19 @@
+//go:build dev
+
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+func RarityTTL() time.Duration {
+	if v := os.Getenv("RARITY_TTL_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	// dev default: short TTL so fixture changes show up
+	return 10 * time.Minute
+}
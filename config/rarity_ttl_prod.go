@@ -0,0 +1,20 @@
+//go:build !dev
+
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// RarityTTL is how long global achievement percentages are trusted. Prod default: 24h.
+// Override with RARITY_TTL_SECONDS.
+func RarityTTL() time.Duration {
+	if v := os.Getenv("RARITY_TTL_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return 24 * time.Hour
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// Retention is the snapshot pruning policy applied after each refresh:
+// every snapshot younger than KeepAll is kept, then one per week until Weekly,
+// then one per month. Completion milestones and the newest two are always kept.
+type Retention struct {
+	Enabled bool
+	KeepAll time.Duration
+	Weekly  time.Duration
+}
+
+// SnapshotRetention returns the pruning policy. Defaults: keep everything for
+// 30 days, weekly for a year, monthly after that. Override with
+// RETENTION_KEEP_ALL_DAYS and RETENTION_WEEKLY_DAYS; RETENTION_DISABLED=1 turns it off.
+func SnapshotRetention() Retention {
+	r := Retention{
+		Enabled: os.Getenv("RETENTION_DISABLED") != "1",
+		KeepAll: 30 * 24 * time.Hour,
+		Weekly:  365 * 24 * time.Hour,
+	}
+	if v := os.Getenv("RETENTION_KEEP_ALL_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			r.KeepAll = time.Duration(n) * 24 * time.Hour
+		}
+	}
+	if v := os.Getenv("RETENTION_WEEKLY_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			r.Weekly = time.Duration(n) * 24 * time.Hour
+		}
+	}
+	if r.Weekly < r.KeepAll {
+		r.Weekly = r.KeepAll
+	}
+	return r
+}
@@ -0,0 +1,48 @@
+//go:build dev
+
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// SchedulerTick is how often the scheduler looks for tracked accounts that are due.
+// Dev default: 5s. Override with SCHEDULER_TICK_SECONDS (0 disables the scheduler).
+func SchedulerTick() time.Duration {
+	if v := os.Getenv("SCHEDULER_TICK_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return 5 * time.Second
+}
+
+// DefaultTrackInterval is used when an account is tracked without an interval.
+// Dev default: 10m. Override with TRACK_INTERVAL_MINUTES.
+func DefaultTrackInterval() time.Duration {
+	if v := os.Getenv("TRACK_INTERVAL_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return time.Duration(n) * time.Minute
+		}
+	}
+	return 10 * time.Minute
+}
+
+// MinTrackInterval is the shortest interval a tracked account may use. Dev: 1m.
+func MinTrackInterval() time.Duration {
+	return time.Minute
+}
+
+// SchedulerBudgetReserve is the share of the daily Steam budget (0–100) kept for
+// interactive refreshes; scheduled runs pause below it. Dev default: 0.
+// Override with SCHEDULER_BUDGET_RESERVE_PCT.
+func SchedulerBudgetReserve() float64 {
+	if v := os.Getenv("SCHEDULER_BUDGET_RESERVE_PCT"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 100 {
+			return f
+		}
+	}
+	return 0
+}
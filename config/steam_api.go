@@ -0,0 +1,30 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// DefaultSteamAPIBaseURL is the public Steam Web API host.
+const DefaultSteamAPIBaseURL = "https://api.steampowered.com"
+
+// SteamAPIBaseURL returns the Steam Web API base URL (no trailing slash).
+// Override with STEAM_API_BASE_URL, e.g. to point at a local fake server.
+func SteamAPIBaseURL() string {
+	if v := strings.TrimSpace(os.Getenv("STEAM_API_BASE_URL")); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return DefaultSteamAPIBaseURL
+}
+
+// DefaultSteamMediaBaseURL hosts game icons referenced by GetOwnedGames (img_icon_url).
+const DefaultSteamMediaBaseURL = "https://media.steampowered.com"
+
+// SteamMediaBaseURL returns the base URL game icon hashes are resolved against.
+// Override with STEAM_MEDIA_BASE_URL (the fake server serves icons too).
+func SteamMediaBaseURL() string {
+	if v := strings.TrimSpace(os.Getenv("STEAM_MEDIA_BASE_URL")); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return DefaultSteamMediaBaseURL
+}
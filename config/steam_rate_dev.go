@@ -0,0 +1,28 @@
+//go:build dev
+
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// Dev default: 10/s (the fake server doesn't mind). Override with STEAM_RPS.
+func SteamRequestsPerSecond() float64 {
+	if v := os.Getenv("STEAM_RPS"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			return f
+		}
+	}
+	return 10
+}
+
+// Dev default: 100000. Override with STEAM_DAILY_BUDGET.
+func SteamDailyBudget() int {
+	if v := os.Getenv("STEAM_DAILY_BUDGET"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 100000
+}
@@ -0,0 +1,30 @@
+//go:build !dev
+
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// SteamRequestsPerSecond is the process-wide Steam call rate. Prod default: 4/s.
+// Override with STEAM_RPS (fractions allowed, e.g. 0.5).
+func SteamRequestsPerSecond() float64 {
+	if v := os.Getenv("STEAM_RPS"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			return f
+		}
+	}
+	return 4
+}
+
+// SteamDailyBudget is the number of Steam calls allowed per UTC day.
+// Prod default: 100000 (Steam's documented key limit). Override with STEAM_DAILY_BUDGET.
+func SteamDailyBudget() int {
+	if v := os.Getenv("STEAM_DAILY_BUDGET"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 100000
+}
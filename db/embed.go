@@ -0,0 +1,19 @@
+package db
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations returns the migration files compiled into the binary, rooted so
+// that 001_init.sql is at the top level (as ApplyMigrations expects).
+func Migrations() fs.FS {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic(err) // "migrations" is a fixed, valid path
+	}
+	return sub
+}
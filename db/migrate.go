@@ -0,0 +1,316 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Migration files sit at the root of an fs.FS (see Migrations): NNN_name.sql
+// applies a change and the optional NNN_name.down.sql reverts it. Versions are
+// the up file names and are applied in lexicographic order.
+const downSuffix = ".down.sql"
+
+var (
+	// ErrMigrationModified means an applied migration file no longer matches the
+	// checksum recorded when it ran. Add a new migration instead of editing old ones.
+	ErrMigrationModified = errors.New("applied migration was modified")
+	// ErrNoDownMigration means a rollback reached a migration without a .down.sql file.
+	ErrNoDownMigration = errors.New("no down migration")
+)
+
+// Migration describes one migration file and whether it has been applied.
+type Migration struct {
+	Version   string     // up file name, e.g. 007_snapshot_timeline.sql
+	Checksum  string     // sha256 of the up file ("" if the file is missing)
+	HasDown   bool       // a matching .down.sql exists
+	Applied   bool       // recorded in schema_migrations
+	AppliedAt *time.Time // nil if pending
+	Modified  bool       // applied, but the file's checksum changed since
+	Missing   bool       // applied, but the file is gone
+}
+
+type migrationFile struct {
+	version  string
+	up       string
+	down     string // "" if none
+	checksum string
+}
+
+type appliedMigration struct {
+	appliedAt time.Time
+	checksum  string // sha256 of the up file when it ran
+}
+
+// ApplyMigrations applies every pending migration in fsys, each in its own
+// transaction, and records it in schema_migrations. It refuses to run if an
+// already-applied file was modified.
+func ApplyMigrations(ctx context.Context, db *sql.DB, fsys fs.FS) error {
+	_, err := MigrateUp(ctx, db, fsys, false)
+	return err
+}
+
+// MigrateUp applies pending migrations and returns their versions in order.
+// With dryRun nothing is written; the versions that would run are returned.
+func MigrateUp(ctx context.Context, db *sql.DB, fsys fs.FS, dryRun bool) ([]string, error) {
+	files, err := loadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		if err := ensureMigrationTable(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify everything before running anything.
+	var pending []migrationFile
+	for _, f := range files {
+		a, ok := applied[f.version]
+		switch {
+		case !ok:
+			pending = append(pending, f)
+		case a.checksum != f.checksum:
+			return nil, fmt.Errorf("%w: %s", ErrMigrationModified, f.version)
+		}
+	}
+
+	const record = `INSERT INTO schema_migrations(version, checksum) VALUES(?, ?);`
+	var ran []string
+	for _, f := range pending {
+		if !dryRun {
+			err := runMigration(ctx, db, f.version, f.up, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, record, f.version, f.checksum)
+				return err
+			})
+			if isDuplicateColumn(err) {
+				// Applied before versions were tracked, back when every file ran on
+				// every start and one whose columns existed was skipped: adopt it.
+				_, err = db.ExecContext(ctx, record, f.version, f.checksum)
+			}
+			if err != nil {
+				return ran, err
+			}
+		}
+		ran = append(ran, f.version)
+	}
+	return ran, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, newest first, using
+// their .down.sql files, and returns the reverted versions in order.
+// With dryRun nothing is written; the versions that would be reverted are returned.
+func MigrateDown(ctx context.Context, db *sql.DB, fsys fs.FS, steps int, dryRun bool) ([]string, error) {
+	if steps <= 0 {
+		return nil, nil
+	}
+	files, err := loadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[string]migrationFile, len(files))
+	for _, f := range files {
+		byVersion[f.version] = f
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+	if steps < len(versions) {
+		versions = versions[:steps]
+	}
+
+	// Check every step has a down file before reverting any of them.
+	for _, v := range versions {
+		if byVersion[v].down == "" {
+			return nil, fmt.Errorf("%w: %s", ErrNoDownMigration, v)
+		}
+	}
+
+	var reverted []string
+	for _, v := range versions {
+		if !dryRun {
+			if err := runMigration(ctx, db, downName(v), byVersion[v].down, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version=?;`, v)
+				return err
+			}); err != nil {
+				return reverted, err
+			}
+		}
+		reverted = append(reverted, v)
+	}
+	return reverted, nil
+}
+
+// MigrationStatus lists every migration file plus any applied version whose
+// file is gone, ordered by version. It never writes to the database.
+func MigrationStatus(ctx context.Context, db *sql.DB, fsys fs.FS) ([]Migration, error) {
+	files, err := loadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Migration, 0, len(files))
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		seen[f.version] = true
+		m := Migration{Version: f.version, Checksum: f.checksum, HasDown: f.down != ""}
+		if a, ok := applied[f.version]; ok {
+			at := a.appliedAt
+			m.Applied = true
+			m.AppliedAt = &at
+			m.Modified = a.checksum != f.checksum
+		}
+		out = append(out, m)
+	}
+	for v, a := range applied {
+		if seen[v] {
+			continue
+		}
+		at := a.appliedAt
+		out = append(out, Migration{Version: v, Applied: true, AppliedAt: &at, Missing: true})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// runMigration executes one file's SQL and its bookkeeping in a single transaction.
+func runMigration(ctx context.Context, db *sql.DB, name, body string, record func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("begin tx for %s: %w", name, err)
+	}
+	if _, err := tx.ExecContext(ctx, body); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("exec %s: %w", name, err)
+	}
+	if err := record(tx); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("record %s: %w", name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %s: %w", name, err)
+	}
+	return nil
+}
+
+// loadMigrations reads the up/down pairs at the root of fsys, sorted by version.
+func loadMigrations(fsys fs.FS) ([]migrationFile, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		// If the directory doesn't exist, consider that a configuration error.
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, errors.New("migrations dir not found")
+		}
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			names[e.Name()] = true
+		}
+	}
+	var files []migrationFile
+	for name := range names {
+		if path.Ext(name) != ".sql" || strings.HasSuffix(name, downSuffix) {
+			continue
+		}
+		up, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		sum := sha256.Sum256(up)
+		f := migrationFile{version: name, up: string(up), checksum: hex.EncodeToString(sum[:])}
+		if names[downName(name)] {
+			down, err := fs.ReadFile(fsys, downName(name))
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", downName(name), err)
+			}
+			f.down = string(down)
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no .sql migration files found")
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].version < files[j].version })
+	return files, nil
+}
+
+func downName(version string) string {
+	return strings.TrimSuffix(version, ".sql") + downSuffix
+}
+
+// ensureMigrationTable creates schema_migrations.
+func ensureMigrationTable(ctx context.Context, db *sql.DB) error {
+	const createTracking = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+  version    TEXT PRIMARY KEY,
+  applied_at DATETIME NOT NULL DEFAULT (datetime('now')),
+  checksum   TEXT NOT NULL  -- sha256 of the up file
+);`
+	if _, err := db.ExecContext(ctx, createTracking); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// appliedMigrations reads schema_migrations. A missing table (fresh DB, or a
+// dry run before the first migration) reads as nothing applied.
+func appliedMigrations(ctx context.Context, db *sql.DB) (map[string]appliedMigration, error) {
+	var tables int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='schema_migrations';`).Scan(&tables); err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	out := map[string]appliedMigration{}
+	if tables == 0 {
+		return out, nil
+	}
+	rows, err := db.QueryContext(ctx, `SELECT version, applied_at, checksum FROM schema_migrations;`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			v  string
+			at sql.NullTime
+			a  appliedMigration
+		)
+		if err := rows.Scan(&v, &at, &a.checksum); err != nil {
+			return nil, err
+		}
+		if at.Valid {
+			a.appliedAt = at.Time.UTC()
+		}
+		out[v] = a
+	}
+	return out, rows.Err()
+}
+
+// isDuplicateColumn reports whether err is SQLite refusing to add a column that already exists.
+func isDuplicateColumn(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "duplicate column name")
+}
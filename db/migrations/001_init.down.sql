@@ -0,0 +1,7 @@
+-- Drops the whole base schema; children first so no cascade is needed.
+DROP TABLE IF EXISTS throttle_gate;
+DROP TABLE IF EXISTS snapshot_achievements;
+DROP TABLE IF EXISTS snapshots;
+DROP TABLE IF EXISTS player_achievement_state;
+DROP TABLE IF EXISTS achievement_catalog;
+DROP TABLE IF EXISTS games;
@@ -0,0 +1,4 @@
+-- Synthetic snapshots can't be told apart once the flag is gone, so drop them.
+DELETE FROM snapshots WHERE synthetic = 1;
+DROP INDEX IF EXISTS idx_snap_user_game_synth;
+ALTER TABLE snapshots DROP COLUMN synthetic;
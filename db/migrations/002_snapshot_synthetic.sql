@@ -0,0 +1,5 @@
+-- Backfilled (synthetic) snapshots are rebuilt from unlock timestamps,
+-- not observed from Steam. Flag them so the UI can tell them apart.
+ALTER TABLE snapshots ADD COLUMN synthetic INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_snap_user_game_synth ON snapshots(steamid, appid, synthetic);
@@ -0,0 +1,10 @@
+-- ===== player profiles (from ISteamUser/GetPlayerSummaries) =====
+CREATE TABLE IF NOT EXISTS players (
+  steamid        TEXT PRIMARY KEY,
+  persona_name   TEXT    NOT NULL DEFAULT '',
+  avatar_url     TEXT    NOT NULL DEFAULT '',
+  profile_url    TEXT    NOT NULL DEFAULT '',
+  visibility     INTEGER NOT NULL DEFAULT 0,  -- communityvisibilitystate: 1 private, 3 public
+  games_private  INTEGER NOT NULL DEFAULT 0,  -- 1 if GetOwnedGames came back empty (game details private)
+  updated_at     DATETIME NOT NULL DEFAULT (datetime('now'))
+);
@@ -0,0 +1,2 @@
+ALTER TABLE achievement_catalog DROP COLUMN global_pct;
+ALTER TABLE games DROP COLUMN rarity_checked_at;
@@ -0,0 +1,4 @@
+-- Global unlock percentage per achievement (GetGlobalAchievementPercentagesForApp),
+-- refreshed on its own TTL tracked per game.
+ALTER TABLE achievement_catalog ADD COLUMN global_pct REAL;   -- NULL = unknown
+ALTER TABLE games ADD COLUMN rarity_checked_at DATETIME;      -- NULL = never
@@ -0,0 +1,4 @@
+ALTER TABLE achievement_catalog DROP COLUMN icon;
+ALTER TABLE achievement_catalog DROP COLUMN icon_gray;
+ALTER TABLE achievement_catalog DROP COLUMN hidden;
+ALTER TABLE achievement_catalog DROP COLUMN default_value;
@@ -0,0 +1,5 @@
+-- Full schema metadata from GetSchemaForGame.
+ALTER TABLE achievement_catalog ADD COLUMN icon          TEXT    NOT NULL DEFAULT '';
+ALTER TABLE achievement_catalog ADD COLUMN icon_gray     TEXT    NOT NULL DEFAULT '';
+ALTER TABLE achievement_catalog ADD COLUMN hidden        INTEGER NOT NULL DEFAULT 0;  -- 0/1
+ALTER TABLE achievement_catalog ADD COLUMN default_value INTEGER NOT NULL DEFAULT 0;
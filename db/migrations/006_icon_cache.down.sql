@@ -0,0 +1,3 @@
+-- Files under images/icons are left behind; delete the directory by hand if needed.
+DROP TABLE IF EXISTS icon_cache;
+ALTER TABLE games DROP COLUMN icon_url;
@@ -0,0 +1,13 @@
+-- Game icons (full URL built from GetOwnedGames img_icon_url).
+ALTER TABLE games ADD COLUMN icon_url TEXT NOT NULL DEFAULT '';
+
+-- Local copies of remote icons, content-addressed under the images dir.
+-- path = '' means the last download failed (see last_error); retried later.
+CREATE TABLE IF NOT EXISTS icon_cache (
+  url        TEXT PRIMARY KEY,
+  path       TEXT     NOT NULL DEFAULT '',  -- relative to the images dir, e.g. icons/ab/ab12...jpg
+  fetched_at DATETIME NOT NULL,
+  last_error TEXT     NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_icon_cache_path ON icon_cache(path);
@@ -0,0 +1,47 @@
+-- Restores UNIQUE(steamid, appid, catalog_hash, state_hash). Repeated states
+-- are collapsed to their oldest snapshot (lowest id), as the old schema did;
+-- later repeats and their achievements are dropped.
+
+CREATE TABLE snapshots_old (
+  id              INTEGER PRIMARY KEY AUTOINCREMENT,
+  steamid         TEXT    NOT NULL,
+  appid           INTEGER NOT NULL,
+  total_done      INTEGER NOT NULL,
+  total_available INTEGER NOT NULL,
+  catalog_hash    TEXT    NOT NULL,
+  state_hash      TEXT    NOT NULL,
+  taken_at        DATETIME NOT NULL DEFAULT (datetime('now')),
+  synthetic       INTEGER NOT NULL DEFAULT 0,
+  UNIQUE (steamid, appid, catalog_hash, state_hash),
+  FOREIGN KEY (appid) REFERENCES games(appid) ON DELETE CASCADE
+);
+
+INSERT INTO snapshots_old(id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic)
+SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic
+FROM snapshots
+WHERE id IN (SELECT MIN(id) FROM snapshots GROUP BY steamid, appid, catalog_hash, state_hash);
+
+CREATE TABLE snapshot_achievements_old (
+  snapshot_id INTEGER NOT NULL,
+  appid       INTEGER NOT NULL,
+  apiname     TEXT    NOT NULL,
+  achieved    INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (snapshot_id, apiname),
+  FOREIGN KEY (snapshot_id) REFERENCES snapshots_old(id) ON DELETE CASCADE,
+  FOREIGN KEY (appid, apiname) REFERENCES achievement_catalog(appid, apiname) ON DELETE CASCADE
+);
+
+INSERT INTO snapshot_achievements_old(snapshot_id, appid, apiname, achieved)
+SELECT snapshot_id, appid, apiname, achieved
+FROM snapshot_achievements
+WHERE snapshot_id IN (SELECT id FROM snapshots_old);
+
+DROP TABLE snapshot_achievements;
+DROP TABLE snapshots;
+
+ALTER TABLE snapshots_old RENAME TO snapshots;
+ALTER TABLE snapshot_achievements_old RENAME TO snapshot_achievements;
+
+CREATE INDEX IF NOT EXISTS idx_snap_user_game_time ON snapshots(steamid, appid, taken_at DESC);
+CREATE INDEX IF NOT EXISTS idx_snap_user_game_synth ON snapshots(steamid, appid, synthetic);
+CREATE INDEX IF NOT EXISTS idx_snapach_app ON snapshot_achievements(appid, apiname);
@@ -0,0 +1,51 @@
+-- Snapshots form a timeline: the same catalog/state may legitimately appear
+-- again later (DLC removed, achievement revoked and re-earned), so drop the
+-- UNIQUE(steamid, appid, catalog_hash, state_hash) constraint. Identical
+-- consecutive states are collapsed by the app on insert instead.
+--
+-- SQLite can't drop a constraint, so both tables are rebuilt. The child table
+-- is copied first and the old pair dropped child-first, so ON DELETE CASCADE
+-- never fires. Renaming rewrites snapshot_achievements_new's foreign key to
+-- point at the final snapshots table. Ids are kept as-is, so running the
+-- rebuild again changes nothing.
+
+CREATE TABLE snapshots_new (
+  id              INTEGER PRIMARY KEY AUTOINCREMENT,
+  steamid         TEXT    NOT NULL,
+  appid           INTEGER NOT NULL,
+  total_done      INTEGER NOT NULL,
+  total_available INTEGER NOT NULL,
+  catalog_hash    TEXT    NOT NULL,
+  state_hash      TEXT    NOT NULL,
+  taken_at        DATETIME NOT NULL DEFAULT (datetime('now')),
+  synthetic       INTEGER NOT NULL DEFAULT 0,
+  FOREIGN KEY (appid) REFERENCES games(appid) ON DELETE CASCADE
+);
+
+INSERT INTO snapshots_new(id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic)
+SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic
+FROM snapshots;
+
+CREATE TABLE snapshot_achievements_new (
+  snapshot_id INTEGER NOT NULL,
+  appid       INTEGER NOT NULL,
+  apiname     TEXT    NOT NULL,
+  achieved    INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (snapshot_id, apiname),
+  FOREIGN KEY (snapshot_id) REFERENCES snapshots_new(id) ON DELETE CASCADE,
+  FOREIGN KEY (appid, apiname) REFERENCES achievement_catalog(appid, apiname) ON DELETE CASCADE
+);
+
+INSERT INTO snapshot_achievements_new(snapshot_id, appid, apiname, achieved)
+SELECT snapshot_id, appid, apiname, achieved
+FROM snapshot_achievements;
+
+DROP TABLE snapshot_achievements;
+DROP TABLE snapshots;
+
+ALTER TABLE snapshots_new RENAME TO snapshots;
+ALTER TABLE snapshot_achievements_new RENAME TO snapshot_achievements;
+
+CREATE INDEX IF NOT EXISTS idx_snap_user_game_time ON snapshots(steamid, appid, taken_at DESC);
+CREATE INDEX IF NOT EXISTS idx_snap_user_game_synth ON snapshots(steamid, appid, synthetic);
+CREATE INDEX IF NOT EXISTS idx_snapach_app ON snapshot_achievements(appid, apiname);
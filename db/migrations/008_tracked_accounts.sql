@@ -0,0 +1,12 @@
+-- Accounts refreshed in the background by the scheduler, each on its own interval.
+-- next_run_at is persisted so the schedule survives restarts.
+CREATE TABLE IF NOT EXISTS tracked_accounts (
+  steamid          TEXT PRIMARY KEY,
+  interval_seconds INTEGER  NOT NULL,
+  next_run_at      DATETIME NOT NULL,
+  last_run_at      DATETIME,                   -- NULL = never ran
+  last_error       TEXT     NOT NULL DEFAULT '', -- '' = last run succeeded
+  created_at       DATETIME NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_tracked_next_run ON tracked_accounts(next_run_at);
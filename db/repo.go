@@ -12,15 +12,50 @@ var ErrNoRows = sql.ErrNoRows
 // ---------- Row models (mirror your schema) ----------
 
 type Game struct {
-	AppID int64
-	Name  string
+	AppID   int64
+	Name    string
+	IconURL string // remote icon URL; "" keeps the stored one
+}
+
+// IconCacheEntry maps a remote icon URL to its local copy.
+type IconCacheEntry struct {
+	URL       string
+	Path      string // relative to the images dir; "" if the download failed
+	FetchedAt time.Time
+	LastError string
+}
+
+// TrackedAccount is a steamid the scheduler refreshes every Interval.
+type TrackedAccount struct {
+	SteamID   string
+	Interval  time.Duration
+	NextRunAt time.Time
+	LastRunAt *time.Time // nil if it never ran
+	LastError string     // "" if the last run succeeded
+	CreatedAt time.Time
+}
+
+// Player is the cached Steam profile for a steamid.
+type Player struct {
+	SteamID      string
+	PersonaName  string
+	AvatarURL    string
+	ProfileURL   string
+	Visibility   int  // communityvisibilitystate: 1 private, 3 public
+	GamesPrivate bool // owned games list was hidden on last refresh
+	UpdatedAt    time.Time
 }
 
 type AchievementDef struct {
-	AppID   int64
-	APIName string
-	Name    string
-	Descr   string
+	AppID        int64
+	APIName      string
+	Name         string
+	Descr        string
+	Icon         string // unlocked icon URL
+	IconGray     string // locked icon URL
+	Hidden       bool   // description is a spoiler until unlocked
+	DefaultValue int
+	GlobalPct    *float64 // % of all players who unlocked it; nil if unknown (not written by UpsertAchievementDefs)
 }
 
 // Player’s current per-achievement state (not snapshot).
@@ -41,6 +76,19 @@ type Snapshot struct {
 	CatalogHash    string
 	StateHash      string
 	TakenAt        time.Time
+	Synthetic      bool // backfilled from unlock times, not observed
+}
+
+// SnapshotPair is the latest snapshot of one game and the one before it.
+type SnapshotPair struct {
+	Curr Snapshot
+	Prev *Snapshot // nil if Curr is the only snapshot
+}
+
+// PruneResult counts the rows removed by PruneSnapshots.
+type PruneResult struct {
+	Snapshots    int64
+	Achievements int64 // snapshot_achievements rows removed with them
 }
 
 type SnapshotAchievement struct {
@@ -62,20 +110,68 @@ type SnapshotInsert struct {
 		APIName  string
 		Achieved bool
 	}
+	TakenAt   time.Time // zero = now
+	Synthetic bool
 }
 
 type Repo interface {
+	UpsertPlayer(ctx context.Context, p Player) error
+	GetPlayer(ctx context.Context, steamid string) (Player, error) // ErrNoRows if none
 	UpsertGame(ctx context.Context, g Game) error
+	GetGame(ctx context.Context, appid int64) (Game, error) // ErrNoRows if none
 	UpsertAchievementDefs(ctx context.Context, defs []AchievementDef) error
+	GetAchievementDefs(ctx context.Context, appid int64) ([]AchievementDef, error)
+	GetGameRarityCache(ctx context.Context, appid int64) (checkedAt *time.Time, err error)
+	UpdateAchievementRarity(ctx context.Context, appid int64, pcts map[string]float64, checkedAt time.Time) error
 	UpsertPlayerAchievementState(ctx context.Context, rows []PlayerAchievementState) error
+	GetPlayerAchievementStates(ctx context.Context, steamid string, appid int64) ([]PlayerAchievementState, error)
 	InsertSnapshot(ctx context.Context, in SnapshotInsert) (int64, error)
 	GetLatestSnapshots(ctx context.Context, steamid string, appid int64, limit int) ([]Snapshot, error)
-	PruneSnapshots(ctx context.Context, steamid string, appid int64, keep int) (int64, error)
+	ListSnapshots(ctx context.Context, steamid string, appid int64) ([]Snapshot, error)                    // oldest first
+	GetSnapshotByID(ctx context.Context, id int64) (Snapshot, error)                                       // ErrNoRows if none
+	GetSnapshotAtOrBefore(ctx context.Context, steamid string, appid int64, t time.Time) (Snapshot, error) // ErrNoRows if none
+	GetPreviousSnapshot(ctx context.Context, s Snapshot) (Snapshot, error)                                 // ErrNoRows if s is the first
+	GetOldestObservedSnapshot(ctx context.Context, steamid string, appid int64) (Snapshot, error)          // ErrNoRows if none
+	ReplaceSyntheticSnapshots(ctx context.Context, steamid string, appid int64, ins []SnapshotInsert) (int, error)
+	PruneSnapshots(ctx context.Context, steamid string, ids []int64) (PruneResult, error)
 	GetSnapshotAchievements(ctx context.Context, snapshotID int64) ([]SnapshotAchievement, error)
 	GetLatestSnapshotAchievementsPair(ctx context.Context, steamid string, appid int64) (prev []SnapshotAchievement, curr []SnapshotAchievement, err error)
 	ListAppIDsWithSnapshots(ctx context.Context, steamid string) ([]int64, error)
+
+	// Set-based loaders for every game the user has snapshots for (one query each).
+	GetLatestSnapshotPairs(ctx context.Context, steamid string) ([]SnapshotPair, error)                                // ordered by appid
+	GetLatestSnapshotPairAchievements(ctx context.Context, steamid string) (map[int64][]SnapshotAchievement, error)    // by snapshot id
+	GetAchievementDefsForUser(ctx context.Context, steamid string) (map[int64][]AchievementDef, error)                 // by appid
+	GetPlayerAchievementStatesForUser(ctx context.Context, steamid string) (map[int64][]PlayerAchievementState, error) // by appid
+	GetGamesForUser(ctx context.Context, steamid string) (map[int64]Game, error)                                       // by appid
+
+	// GetSnapshotsInRange returns, per game, curr: the newest snapshot at or before to
+	// (latest when zero) and prev: the newest at or before from (the one before curr
+	// when from is zero). Both are ordered by appid; one query.
+	GetSnapshotsInRange(ctx context.Context, steamid string, from, to time.Time) (prev, curr []Snapshot, err error)
+	GetSnapshotsInRangeAchievements(ctx context.Context, steamid string, from, to time.Time) (map[int64][]SnapshotAchievement, error) // by snapshot id
+	ListSnapshotsForUser(ctx context.Context, steamid string) (map[int64][]Snapshot, error)                                           // by appid, oldest first
+
 	GetLastRefreshAt(ctx context.Context, steamid string) (time.Time, error) // ErrNoRows if none
 	SetLastRefreshNow(ctx context.Context, steamid string, now time.Time) error
+	// TryAcquireRefresh atomically stamps the gate at now unless it was stamped less
+	// than window ago, in which case it returns false. last is the earlier stamp
+	// either way (zero if there was none), for ReleaseRefresh.
+	TryAcquireRefresh(ctx context.Context, steamid string, now time.Time, window time.Duration) (acquired bool, last time.Time, err error)
+	ReleaseRefresh(ctx context.Context, steamid string, claimed, prev time.Time) error // undoes an acquire
+	ListUncachedIconURLs(ctx context.Context, retryFailedBefore time.Time, limit int) ([]string, error)
+	PutIconCache(ctx context.Context, e IconCacheEntry) error
+	GetIconPaths(ctx context.Context, urls []string) (map[string]string, error) // url -> path, cached ones only
+	PruneIconCache(ctx context.Context) (removed int64, err error)
+	ListIconPaths(ctx context.Context) ([]string, error)
 	GetGameSchemaCache(ctx context.Context, appid int64) (achCount *int, checkedAt *time.Time, err error)
 	UpdateGameSchemaCache(ctx context.Context, appid int64, achCount int, checkedAt time.Time) error
+
+	// Background refresh schedule.
+	UpsertTrackedAccount(ctx context.Context, steamid string, interval time.Duration, nextRunAt time.Time) error
+	DeleteTrackedAccount(ctx context.Context, steamid string) (bool, error)        // false if it wasn't tracked
+	GetTrackedAccount(ctx context.Context, steamid string) (TrackedAccount, error) // ErrNoRows if none
+	ListTrackedAccounts(ctx context.Context) ([]TrackedAccount, error)             // by next run
+	ListDueTrackedAccounts(ctx context.Context, now time.Time, limit int) ([]TrackedAccount, error)
+	RecordTrackedRun(ctx context.Context, steamid string, ranAt, nextRunAt time.Time, lastError string) error
 }
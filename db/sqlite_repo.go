@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 )
 
@@ -15,28 +16,75 @@ func NewRepo(sqldb *sql.DB) Repo {
 	return &sqliteRepo{db: sqldb}
 }
 
+// -------------------- Players --------------------
+
+func (r *sqliteRepo) UpsertPlayer(ctx context.Context, p Player) error {
+	const q = `
+INSERT INTO players(steamid, persona_name, avatar_url, profile_url, visibility, games_private, updated_at)
+VALUES(?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(steamid) DO UPDATE SET
+  persona_name  = excluded.persona_name,
+  avatar_url    = excluded.avatar_url,
+  profile_url   = excluded.profile_url,
+  visibility    = excluded.visibility,
+  games_private = excluded.games_private,
+  updated_at    = excluded.updated_at;`
+	updated := p.UpdatedAt
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	_, err := r.db.ExecContext(ctx, q, p.SteamID, p.PersonaName, p.AvatarURL, p.ProfileURL, p.Visibility, boolToInt(p.GamesPrivate), updated.UTC())
+	return err
+}
+
+func (r *sqliteRepo) GetPlayer(ctx context.Context, steamid string) (Player, error) {
+	const q = `
+SELECT steamid, persona_name, avatar_url, profile_url, visibility, games_private, updated_at
+FROM players
+WHERE steamid = ?;`
+	var p Player
+	var gamesPrivate int
+	if err := r.db.QueryRowContext(ctx, q, steamid).Scan(&p.SteamID, &p.PersonaName, &p.AvatarURL, &p.ProfileURL, &p.Visibility, &gamesPrivate, &p.UpdatedAt); err != nil {
+		return Player{}, err
+	}
+	p.GamesPrivate = gamesPrivate == 1
+	return p, nil
+}
+
 // -------------------- Catalog & metadata --------------------
 
 func (r *sqliteRepo) UpsertGame(ctx context.Context, g Game) error {
 	const q = `
-INSERT INTO games(appid, name)
-VALUES(?, ?)
+INSERT INTO games(appid, name, icon_url)
+VALUES(?, ?, ?)
 ON CONFLICT(appid) DO UPDATE SET
-  name = excluded.name;`
-	_, err := r.db.ExecContext(ctx, q, g.AppID, g.Name)
+  name     = excluded.name,
+  icon_url = CASE WHEN excluded.icon_url <> '' THEN excluded.icon_url ELSE games.icon_url END;`
+	_, err := r.db.ExecContext(ctx, q, g.AppID, g.Name, g.IconURL)
 	return err
 }
 
+func (r *sqliteRepo) GetGame(ctx context.Context, appid int64) (Game, error) {
+	const q = `SELECT appid, name, icon_url FROM games WHERE appid=?;`
+	var g Game
+	err := r.db.QueryRowContext(ctx, q, appid).Scan(&g.AppID, &g.Name, &g.IconURL)
+	return g, err
+}
+
 func (r *sqliteRepo) UpsertAchievementDefs(ctx context.Context, defs []AchievementDef) error {
 	if len(defs) == 0 {
 		return nil
 	}
 	const q = `
-INSERT INTO achievement_catalog(appid, apiname, name, descr)
-VALUES(?, ?, ?, ?)
+INSERT INTO achievement_catalog(appid, apiname, name, descr, icon, icon_gray, hidden, default_value)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?)
 ON CONFLICT(appid, apiname) DO UPDATE SET
-  name  = excluded.name,
-  descr = excluded.descr;`
+  name          = excluded.name,
+  descr         = excluded.descr,
+  icon          = excluded.icon,
+  icon_gray     = excluded.icon_gray,
+  hidden        = excluded.hidden,
+  default_value = excluded.default_value;`
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -48,11 +96,79 @@ ON CONFLICT(appid, apiname) DO UPDATE SET
 	}
 	defer stmt.Close()
 	for _, d := range defs {
-		if _, err := stmt.ExecContext(ctx, d.AppID, d.APIName, d.Name, d.Descr); err != nil {
+		if _, err := stmt.ExecContext(ctx, d.AppID, d.APIName, d.Name, d.Descr, d.Icon, d.IconGray, boolToInt(d.Hidden), d.DefaultValue); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetAchievementDefs returns the catalog for appid, ordered by apiname.
+func (r *sqliteRepo) GetAchievementDefs(ctx context.Context, appid int64) ([]AchievementDef, error) {
+	const q = `
+SELECT appid, apiname, name, descr, icon, icon_gray, hidden, default_value, global_pct
+FROM achievement_catalog
+WHERE appid=?
+ORDER BY apiname ASC;`
+	rows, err := r.db.QueryContext(ctx, q, appid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []AchievementDef
+	for rows.Next() {
+		d, err := scanAchievementDef(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetGameRarityCache returns when global percentages were last fetched for appid
+// (nil if never). ErrNoRows if the game is unknown.
+func (r *sqliteRepo) GetGameRarityCache(ctx context.Context, appid int64) (*time.Time, error) {
+	const q = `SELECT rarity_checked_at FROM games WHERE appid=?;`
+	var ts sql.NullTime
+	if err := r.db.QueryRowContext(ctx, q, appid).Scan(&ts); err != nil {
+		return nil, err
+	}
+	if !ts.Valid {
+		return nil, nil
+	}
+	t := ts.Time
+	return &t, nil
+}
+
+// UpdateAchievementRarity stores global percentages for appid's catalog entries
+// and stamps the game's rarity cache. Apinames not in the catalog are ignored.
+func (r *sqliteRepo) UpdateAchievementRarity(ctx context.Context, appid int64, pcts map[string]float64, checkedAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, `UPDATE achievement_catalog SET global_pct=? WHERE appid=? AND apiname=?;`)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for api, pct := range pcts {
+		if _, err := stmt.ExecContext(ctx, pct, appid, api); err != nil {
 			_ = tx.Rollback()
 			return err
 		}
 	}
+	if _, err := tx.ExecContext(ctx, `UPDATE games SET rarity_checked_at=? WHERE appid=?;`, checkedAt.UTC(), appid); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
 	return tx.Commit()
 }
 
@@ -93,36 +209,97 @@ ON CONFLICT(steamid, appid, apiname) DO UPDATE SET
 	return tx.Commit()
 }
 
+// GetPlayerAchievementStates returns the stored current state for (steamid, appid), by apiname.
+func (r *sqliteRepo) GetPlayerAchievementStates(ctx context.Context, steamid string, appid int64) ([]PlayerAchievementState, error) {
+	const q = `
+SELECT apiname, achieved, unlock_time
+FROM player_achievement_state
+WHERE steamid=? AND appid=?
+ORDER BY apiname ASC;`
+	rows, err := r.db.QueryContext(ctx, q, steamid, appid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []PlayerAchievementState
+	for rows.Next() {
+		var api string
+		var achInt int
+		var ts sql.NullTime
+		if err := rows.Scan(&api, &achInt, &ts); err != nil {
+			return nil, err
+		}
+		st := PlayerAchievementState{
+			SteamID:  steamid,
+			AppID:    appid,
+			APIName:  api,
+			Achieved: achInt == 1,
+		}
+		if ts.Valid {
+			t := ts.Time.UTC()
+			st.UnlockTime = &t
+		}
+		out = append(out, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // -------------------- Snapshots --------------------
 
+// InsertSnapshot appends a snapshot to the (steamid, appid) timeline. If the
+// snapshot just before it has the same catalog/state hashes (and the same
+// synthetic flag) nothing is written and that snapshot's id is returned, so
+// repeated refreshes collapse while A → B → A still yields three entries.
 func (r *sqliteRepo) InsertSnapshot(ctx context.Context, in SnapshotInsert) (int64, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
-	// 1) insert (or dedupe) snapshot
-	const insSnap = `
-INSERT INTO snapshots(steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at)
-VALUES(?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
-ON CONFLICT(steamid, appid, catalog_hash, state_hash) DO NOTHING;`
-	if _, err := tx.ExecContext(ctx, insSnap, in.SteamID, in.AppID, in.TotalDone, in.TotalAvailable, in.CatalogHash, in.StateHash); err != nil {
+	// 1) dedupe against the neighbouring (newest at or before) snapshot only
+	const selPrev = `
+SELECT id, catalog_hash, state_hash, synthetic FROM snapshots
+WHERE steamid=? AND appid=? AND taken_at <= COALESCE(?, CURRENT_TIMESTAMP)
+ORDER BY taken_at DESC, id DESC
+LIMIT 1;`
+	var (
+		prevID             int64
+		prevCat, prevState string
+		prevSynth          int
+	)
+	err = tx.QueryRowContext(ctx, selPrev, in.SteamID, in.AppID, takenAtArg(in.TakenAt)).Scan(&prevID, &prevCat, &prevState, &prevSynth)
+	switch {
+	case err == nil:
+		if prevCat == in.CatalogHash && prevState == in.StateHash && (prevSynth == 1) == in.Synthetic {
+			if err := tx.Commit(); err != nil {
+				return 0, err
+			}
+			return prevID, nil
+		}
+	case !errors.Is(err, sql.ErrNoRows):
 		_ = tx.Rollback()
 		return 0, err
 	}
 
-	// 2) get the id (new or existing)
-	var id int64
-	const selID = `
-SELECT id FROM snapshots
-WHERE steamid=? AND appid=? AND catalog_hash=? AND state_hash=?
-ORDER BY taken_at DESC
-LIMIT 1;`
-	if err := tx.QueryRowContext(ctx, selID, in.SteamID, in.AppID, in.CatalogHash, in.StateHash).Scan(&id); err != nil {
+	// 2) insert the new timeline entry
+	const insSnap = `
+INSERT INTO snapshots(steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic)
+VALUES(?, ?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?);`
+	res, err := tx.ExecContext(ctx, insSnap, in.SteamID, in.AppID, in.TotalDone, in.TotalAvailable, in.CatalogHash, in.StateHash, takenAtArg(in.TakenAt), boolToInt(in.Synthetic))
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
 		_ = tx.Rollback()
 		return 0, err
 	}
 
-	// 3) upsert snapshot_achievements for this snapshot
+	// 3) write snapshot_achievements for the new snapshot
 	if len(in.Achievements) > 0 {
 		const insA = `
 INSERT INTO snapshot_achievements(snapshot_id, appid, apiname, achieved)
@@ -153,7 +330,7 @@ func (r *sqliteRepo) GetLatestSnapshots(ctx context.Context, steamid string, app
 		limit = 2
 	}
 	const q = `
-SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at
+SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic
 FROM snapshots
 WHERE steamid=? AND appid=?
 ORDER BY taken_at DESC, id DESC
@@ -166,12 +343,10 @@ LIMIT ?;`
 
 	var out []Snapshot
 	for rows.Next() {
-		var s Snapshot
-		var taken time.Time
-		if scanErr := rows.Scan(&s.ID, &s.SteamID, &s.AppID, &s.TotalDone, &s.TotalAvailable, &s.CatalogHash, &s.StateHash, &taken); scanErr != nil {
+		s, scanErr := scanSnapshot(rows)
+		if scanErr != nil {
 			return nil, scanErr
 		}
-		s.TakenAt = taken
 		out = append(out, s)
 	}
 	if err := rows.Err(); err != nil {
@@ -180,15 +355,162 @@ LIMIT ?;`
 	return out, nil
 }
 
-func (r *sqliteRepo) PruneSnapshots(ctx context.Context, steamid string, appid int64, keep int) (int64, error) {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
+// ListSnapshots returns every snapshot for (steamid, appid), oldest first.
+func (r *sqliteRepo) ListSnapshots(ctx context.Context, steamid string, appid int64) ([]Snapshot, error) {
+	const q = `
+SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic
+FROM snapshots
+WHERE steamid=? AND appid=?
+ORDER BY taken_at ASC, id ASC;`
+	rows, err := r.db.QueryContext(ctx, q, steamid, appid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Snapshot
+	for rows.Next() {
+		s, scanErr := scanSnapshot(rows)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		out = append(out, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetOldestObservedSnapshot returns the first non-synthetic snapshot for (steamid, appid).
+func (r *sqliteRepo) GetOldestObservedSnapshot(ctx context.Context, steamid string, appid int64) (Snapshot, error) {
+	const q = `
+SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic
+FROM snapshots
+WHERE steamid=? AND appid=? AND synthetic=0
+ORDER BY taken_at ASC, id ASC
+LIMIT 1;`
+	return scanSnapshot(r.db.QueryRowContext(ctx, q, steamid, appid))
+}
+
+// ListSnapshotsForUser returns every snapshot of steamid, grouped by appid, oldest first.
+func (r *sqliteRepo) ListSnapshotsForUser(ctx context.Context, steamid string) (map[int64][]Snapshot, error) {
+	const q = `
+SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic
+FROM snapshots
+WHERE steamid=?
+ORDER BY appid ASC, taken_at ASC, id ASC;`
+	rows, err := r.db.QueryContext(ctx, q, steamid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int64][]Snapshot{}
+	for rows.Next() {
+		s, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		out[s.AppID] = append(out[s.AppID], s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetSnapshotByID returns one snapshot (ErrNoRows if none).
+func (r *sqliteRepo) GetSnapshotByID(ctx context.Context, id int64) (Snapshot, error) {
+	const q = `
+SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic
+FROM snapshots
+WHERE id=?;`
+	return scanSnapshot(r.db.QueryRowContext(ctx, q, id))
+}
+
+// GetSnapshotAtOrBefore returns the newest snapshot taken at or before t (ErrNoRows if none).
+func (r *sqliteRepo) GetSnapshotAtOrBefore(ctx context.Context, steamid string, appid int64, t time.Time) (Snapshot, error) {
+	const q = `
+SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic
+FROM snapshots
+WHERE steamid=? AND appid=? AND taken_at <= ?
+ORDER BY taken_at DESC, id DESC
+LIMIT 1;`
+	return scanSnapshot(r.db.QueryRowContext(ctx, q, steamid, appid, t.UTC()))
+}
+
+// GetPreviousSnapshot returns the snapshot just before s for the same steamid/appid
+// (ErrNoRows if s is the first).
+func (r *sqliteRepo) GetPreviousSnapshot(ctx context.Context, s Snapshot) (Snapshot, error) {
+	const q = `
+SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic
+FROM snapshots
+WHERE steamid=? AND appid=?
+  AND (taken_at < (SELECT taken_at FROM snapshots WHERE id=?)
+    OR (taken_at = (SELECT taken_at FROM snapshots WHERE id=?) AND id < ?))
+ORDER BY taken_at DESC, id DESC
+LIMIT 1;`
+	// Compare against the stored taken_at, not a re-encoded time.Time, so formats match.
+	return scanSnapshot(r.db.QueryRowContext(ctx, q, s.SteamID, s.AppID, s.ID, s.ID, s.ID))
+}
+
+// ReplaceSyntheticSnapshots atomically drops the backfilled snapshots for (steamid, appid)
+// and inserts ins (oldest first) in their place. An entry identical to the one
+// before it in ins (same catalog/state hash) is skipped. Returns the number inserted.
+func (r *sqliteRepo) ReplaceSyntheticSnapshots(ctx context.Context, steamid string, appid int64, ins []SnapshotInsert) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
-	n, perr := r.pruneTx(ctx, tx, steamid, appid, keep)
-	if perr != nil {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM snapshots WHERE steamid=? AND appid=? AND synthetic=1;`, steamid, appid); err != nil {
 		_ = tx.Rollback()
-		return 0, perr
+		return 0, err
+	}
+
+	const insSnap = `
+INSERT INTO snapshots(steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic)
+VALUES(?, ?, ?, ?, ?, ?, ?, 1);`
+	const insA = `
+INSERT INTO snapshot_achievements(snapshot_id, appid, apiname, achieved)
+VALUES(?, ?, ?, ?);`
+	snapStmt, err := tx.PrepareContext(ctx, insSnap)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	defer snapStmt.Close()
+	achStmt, err := tx.PrepareContext(ctx, insA)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+	defer achStmt.Close()
+
+	n := 0
+	var prevCat, prevState string
+	for _, in := range ins {
+		if in.CatalogHash == prevCat && in.StateHash == prevState {
+			continue
+		}
+		prevCat, prevState = in.CatalogHash, in.StateHash
+		res, err := snapStmt.ExecContext(ctx, steamid, appid, in.TotalDone, in.TotalAvailable, in.CatalogHash, in.StateHash, in.TakenAt.UTC())
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, err
+		}
+		for _, a := range in.Achievements {
+			if _, err := achStmt.ExecContext(ctx, id, appid, a.APIName, boolToInt(a.Achieved)); err != nil {
+				_ = tx.Rollback()
+				return 0, err
+			}
+		}
+		n++
 	}
 	if err := tx.Commit(); err != nil {
 		return 0, err
@@ -196,24 +518,55 @@ func (r *sqliteRepo) PruneSnapshots(ctx context.Context, steamid string, appid i
 	return n, nil
 }
 
-func (r *sqliteRepo) pruneTx(ctx context.Context, tx *sql.Tx, steamid string, appid int64, keep int) (int64, error) {
-	if keep < 0 {
-		return 0, errors.New("keep must be >= 0")
+// PruneSnapshots deletes the given snapshots of steamid, across games, together with
+// their snapshot_achievements in one transaction. Ids of other accounts are ignored.
+func (r *sqliteRepo) PruneSnapshots(ctx context.Context, steamid string, ids []int64) (PruneResult, error) {
+	var res PruneResult
+	if len(ids) == 0 {
+		return res, nil
 	}
-	const q = `
-DELETE FROM snapshots
-WHERE id IN (
-  SELECT id FROM snapshots
-  WHERE steamid=? AND appid=?
-  ORDER BY taken_at DESC, id DESC
-  LIMIT -1 OFFSET ?
-);`
-	res, err := tx.ExecContext(ctx, q, steamid, appid, keep)
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		return 0, err
+		return res, err
 	}
-	aff, _ := res.RowsAffected()
-	return aff, nil
+	for len(ids) > 0 {
+		n := min(len(ids), inListChunk)
+		if err := pruneTx(ctx, tx, steamid, ids[:n], &res); err != nil {
+			_ = tx.Rollback()
+			return PruneResult{}, err
+		}
+		ids = ids[n:]
+	}
+	if err := tx.Commit(); err != nil {
+		return PruneResult{}, err
+	}
+	return res, nil
+}
+
+func pruneTx(ctx context.Context, tx *sql.Tx, steamid string, ids []int64, res *PruneResult) error {
+	args := make([]any, 0, len(ids)+1)
+	args = append(args, steamid)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	in := `(?` + strings.Repeat(",?", len(ids)-1) + `)`
+
+	// Count the children first: cascaded deletes don't show up in RowsAffected.
+	var achRows int64
+	const countA = `
+SELECT COUNT(*) FROM snapshot_achievements
+WHERE snapshot_id IN (SELECT id FROM snapshots WHERE steamid=? AND id IN `
+	if err := tx.QueryRowContext(ctx, countA+in+`);`, args...).Scan(&achRows); err != nil {
+		return err
+	}
+	out, err := tx.ExecContext(ctx, `DELETE FROM snapshots WHERE steamid=? AND id IN `+in+`;`, args...)
+	if err != nil {
+		return err
+	}
+	n, _ := out.RowsAffected()
+	res.Snapshots += n
+	res.Achievements += achRows
+	return nil
 }
 
 // GetSnapshotAchievements returns all (apiname, achieved) for the given snapshot id.
@@ -321,6 +674,249 @@ ORDER BY appid ASC;`
 	return out, nil
 }
 
+// -------------------- Set-based loaders (whole account) --------------------
+
+// latestPairCTE ranks each game's snapshots newest first; rn 1 is current, rn 2 previous.
+const latestPairCTE = `
+WITH ranked AS (
+  SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic,
+         ROW_NUMBER() OVER (PARTITION BY appid ORDER BY taken_at DESC, id DESC) AS rn
+  FROM snapshots
+  WHERE steamid = ?
+)`
+
+func (r *sqliteRepo) GetLatestSnapshotPairs(ctx context.Context, steamid string) ([]SnapshotPair, error) {
+	q := latestPairCTE + `
+SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic
+FROM ranked
+WHERE rn <= 2
+ORDER BY appid ASC, rn ASC;`
+	rows, err := r.db.QueryContext(ctx, q, steamid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []SnapshotPair
+	for rows.Next() {
+		s, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		// Rows arrive current-then-previous per appid.
+		if n := len(out); n > 0 && out[n-1].Curr.AppID == s.AppID {
+			prev := s
+			out[n-1].Prev = &prev
+			continue
+		}
+		out = append(out, SnapshotPair{Curr: s})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *sqliteRepo) GetLatestSnapshotPairAchievements(ctx context.Context, steamid string) (map[int64][]SnapshotAchievement, error) {
+	q := latestPairCTE + `
+SELECT sa.snapshot_id, sa.apiname, sa.achieved
+FROM ranked
+JOIN snapshot_achievements sa ON sa.snapshot_id = ranked.id
+WHERE ranked.rn <= 2
+ORDER BY sa.snapshot_id ASC, sa.apiname ASC;`
+	rows, err := r.db.QueryContext(ctx, q, steamid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int64][]SnapshotAchievement{}
+	for rows.Next() {
+		var sa SnapshotAchievement
+		var achInt int
+		if err := rows.Scan(&sa.SnapshotID, &sa.APIName, &achInt); err != nil {
+			return nil, err
+		}
+		sa.Achieved = achInt == 1
+		out[sa.SnapshotID] = append(out[sa.SnapshotID], sa)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// rangeCTE picks the snapshots GetSnapshotsInRange returns: side 1 is each game's
+// newest at or before to, side 0 the newest at or before from, or rn 2 of at_to when
+// from is unset. Bind with rangeArgs.
+const rangeCTE = `
+WITH at_to AS (
+  SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic,
+         ROW_NUMBER() OVER (PARTITION BY appid ORDER BY taken_at DESC, id DESC) AS rn
+  FROM snapshots
+  WHERE steamid = ? AND (? = 1 OR taken_at <= ?)
+), at_from AS (
+  SELECT id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic,
+         ROW_NUMBER() OVER (PARTITION BY appid ORDER BY taken_at DESC, id DESC) AS rn
+  FROM snapshots
+  WHERE steamid = ? AND ? = 0 AND taken_at <= ?
+), picked AS (
+  SELECT 1 AS side, * FROM at_to WHERE rn = 1
+  UNION ALL
+  SELECT 0, * FROM at_to WHERE rn = 2 AND ? = 1
+  UNION ALL
+  SELECT 0, * FROM at_from WHERE rn = 1
+)`
+
+func rangeArgs(steamid string, from, to time.Time) []any {
+	return []any{
+		steamid, boolToInt(to.IsZero()), to.UTC(),
+		steamid, boolToInt(from.IsZero()), from.UTC(),
+		boolToInt(from.IsZero()),
+	}
+}
+
+func (r *sqliteRepo) GetSnapshotsInRange(ctx context.Context, steamid string, from, to time.Time) (prev, curr []Snapshot, err error) {
+	q := rangeCTE + `
+SELECT side, id, steamid, appid, total_done, total_available, catalog_hash, state_hash, taken_at, synthetic
+FROM picked
+ORDER BY appid ASC;`
+	rows, err := r.db.QueryContext(ctx, q, rangeArgs(steamid, from, to)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var side int
+		s, err := scanSnapshot(sideScanner{rows, &side})
+		if err != nil {
+			return nil, nil, err
+		}
+		if side == 1 {
+			curr = append(curr, s)
+		} else {
+			prev = append(prev, s)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return prev, curr, nil
+}
+
+func (r *sqliteRepo) GetSnapshotsInRangeAchievements(ctx context.Context, steamid string, from, to time.Time) (map[int64][]SnapshotAchievement, error) {
+	q := rangeCTE + `
+SELECT DISTINCT sa.snapshot_id, sa.apiname, sa.achieved
+FROM picked
+JOIN snapshot_achievements sa ON sa.snapshot_id = picked.id
+ORDER BY sa.snapshot_id ASC, sa.apiname ASC;`
+	rows, err := r.db.QueryContext(ctx, q, rangeArgs(steamid, from, to)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int64][]SnapshotAchievement{}
+	for rows.Next() {
+		var sa SnapshotAchievement
+		var achInt int
+		if err := rows.Scan(&sa.SnapshotID, &sa.APIName, &achInt); err != nil {
+			return nil, err
+		}
+		sa.Achieved = achInt == 1
+		out[sa.SnapshotID] = append(out[sa.SnapshotID], sa)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *sqliteRepo) GetAchievementDefsForUser(ctx context.Context, steamid string) (map[int64][]AchievementDef, error) {
+	const q = `
+SELECT appid, apiname, name, descr, icon, icon_gray, hidden, default_value, global_pct
+FROM achievement_catalog
+WHERE appid IN (SELECT DISTINCT appid FROM snapshots WHERE steamid=?)
+ORDER BY appid ASC, apiname ASC;`
+	rows, err := r.db.QueryContext(ctx, q, steamid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int64][]AchievementDef{}
+	for rows.Next() {
+		d, err := scanAchievementDef(rows)
+		if err != nil {
+			return nil, err
+		}
+		out[d.AppID] = append(out[d.AppID], d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *sqliteRepo) GetPlayerAchievementStatesForUser(ctx context.Context, steamid string) (map[int64][]PlayerAchievementState, error) {
+	const q = `
+SELECT appid, apiname, achieved, unlock_time
+FROM player_achievement_state
+WHERE steamid=?
+ORDER BY appid ASC, apiname ASC;`
+	rows, err := r.db.QueryContext(ctx, q, steamid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int64][]PlayerAchievementState{}
+	for rows.Next() {
+		st := PlayerAchievementState{SteamID: steamid}
+		var achInt int
+		var ts sql.NullTime
+		if err := rows.Scan(&st.AppID, &st.APIName, &achInt, &ts); err != nil {
+			return nil, err
+		}
+		st.Achieved = achInt == 1
+		if ts.Valid {
+			t := ts.Time.UTC()
+			st.UnlockTime = &t
+		}
+		out[st.AppID] = append(out[st.AppID], st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *sqliteRepo) GetGamesForUser(ctx context.Context, steamid string) (map[int64]Game, error) {
+	const q = `
+SELECT appid, name, icon_url
+FROM games
+WHERE appid IN (SELECT DISTINCT appid FROM snapshots WHERE steamid=?);`
+	rows, err := r.db.QueryContext(ctx, q, steamid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int64]Game{}
+	for rows.Next() {
+		var g Game
+		if err := rows.Scan(&g.AppID, &g.Name, &g.IconURL); err != nil {
+			return nil, err
+		}
+		out[g.AppID] = g
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (r *sqliteRepo) GetLastRefreshAt(ctx context.Context, steamid string) (time.Time, error) {
 	const q = `SELECT last_refresh_at FROM throttle_gate WHERE steamid = ?;`
 	var t time.Time
@@ -342,6 +938,49 @@ ON CONFLICT(steamid) DO UPDATE SET
 	return err
 }
 
+// TryAcquireRefresh checks and stamps the gate in one transaction, so two parallel
+// requests can't both see an open window. last is the stamp it found either way.
+func (r *sqliteRepo) TryAcquireRefresh(ctx context.Context, steamid string, now time.Time, window time.Duration) (bool, time.Time, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var last time.Time
+	err = tx.QueryRowContext(ctx, `SELECT last_refresh_at FROM throttle_gate WHERE steamid = ?;`, steamid).Scan(&last)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, time.Time{}, err
+	}
+	if err == nil && last.After(now.Add(-window)) {
+		return false, last, nil
+	}
+	const q = `
+INSERT INTO throttle_gate(steamid, last_refresh_at)
+VALUES(?, ?)
+ON CONFLICT(steamid) DO UPDATE SET
+  last_refresh_at = excluded.last_refresh_at;`
+	if _, err := tx.ExecContext(ctx, q, steamid, now.UTC()); err != nil {
+		return false, time.Time{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, time.Time{}, err
+	}
+	return true, last, nil
+}
+
+// ReleaseRefresh puts the gate back to prev (no stamp when zero), provided it still
+// holds the stamp claimed by TryAcquireRefresh.
+func (r *sqliteRepo) ReleaseRefresh(ctx context.Context, steamid string, claimed, prev time.Time) error {
+	if prev.IsZero() {
+		_, err := r.db.ExecContext(ctx, `DELETE FROM throttle_gate WHERE steamid = ? AND last_refresh_at = ?;`, steamid, claimed.UTC())
+		return err
+	}
+	const q = `UPDATE throttle_gate SET last_refresh_at = ? WHERE steamid = ? AND last_refresh_at = ?;`
+	_, err := r.db.ExecContext(ctx, q, prev.UTC(), steamid, claimed.UTC())
+	return err
+}
+
 func (r *sqliteRepo) GetGameSchemaCache(ctx context.Context, appid int64) (*int, *time.Time, error) {
 	const q = `SELECT achievements_count, schema_checked_at FROM games WHERE appid=?;`
 	var ach sql.NullInt64
@@ -373,6 +1012,260 @@ ON CONFLICT(appid) DO UPDATE SET
 	return err
 }
 
+// -------------------- Icon cache --------------------
+
+// iconRefsSQL lists every icon URL still referenced by the catalog or games.
+const iconRefsSQL = `
+SELECT icon AS url FROM achievement_catalog WHERE icon <> ''
+UNION SELECT icon_gray FROM achievement_catalog WHERE icon_gray <> ''
+UNION SELECT icon_url  FROM games WHERE icon_url <> ''`
+
+// ListUncachedIconURLs returns referenced icon URLs with no local copy yet,
+// including failed downloads last attempted before retryFailedBefore.
+func (r *sqliteRepo) ListUncachedIconURLs(ctx context.Context, retryFailedBefore time.Time, limit int) ([]string, error) {
+	q := `
+SELECT refs.url
+FROM (` + iconRefsSQL + `) AS refs
+LEFT JOIN icon_cache c ON c.url = refs.url
+WHERE c.url IS NULL OR (c.path = '' AND c.fetched_at < ?)
+ORDER BY refs.url
+LIMIT ?;`
+	rows, err := r.db.QueryContext(ctx, q, retryFailedBefore.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *sqliteRepo) PutIconCache(ctx context.Context, e IconCacheEntry) error {
+	const q = `
+INSERT INTO icon_cache(url, path, fetched_at, last_error)
+VALUES(?, ?, ?, ?)
+ON CONFLICT(url) DO UPDATE SET
+  path       = excluded.path,
+  fetched_at = excluded.fetched_at,
+  last_error = excluded.last_error;`
+	_, err := r.db.ExecContext(ctx, q, e.URL, e.Path, e.FetchedAt.UTC(), e.LastError)
+	return err
+}
+
+// inListChunk keeps IN (...) lists under SQLite's bound-parameter limit.
+const inListChunk = 500
+
+func (r *sqliteRepo) GetIconPaths(ctx context.Context, urls []string) (map[string]string, error) {
+	out := make(map[string]string, len(urls))
+	for len(urls) > 0 {
+		n := min(len(urls), inListChunk)
+		if err := r.getIconPaths(ctx, urls[:n], out); err != nil {
+			return nil, err
+		}
+		urls = urls[n:]
+	}
+	return out, nil
+}
+
+func (r *sqliteRepo) getIconPaths(ctx context.Context, urls []string, out map[string]string) error {
+	args := make([]any, len(urls))
+	for i, u := range urls {
+		args[i] = u
+	}
+	q := `SELECT url, path FROM icon_cache WHERE path <> '' AND url IN (?` + strings.Repeat(",?", len(urls)-1) + `);`
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u, p string
+		if err := rows.Scan(&u, &p); err != nil {
+			return err
+		}
+		out[u] = p
+	}
+	return rows.Err()
+}
+
+// PruneIconCache forgets icons no longer referenced by the catalog or games.
+// Files are left on disk; compare ListIconPaths to find orphans.
+func (r *sqliteRepo) PruneIconCache(ctx context.Context) (int64, error) {
+	q := `DELETE FROM icon_cache WHERE url NOT IN (` + iconRefsSQL + `);`
+	res, err := r.db.ExecContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ListIconPaths returns every local icon file still in use.
+func (r *sqliteRepo) ListIconPaths(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT path FROM icon_cache WHERE path <> '';`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// -------------------- Tracked accounts --------------------
+
+const trackedCols = `steamid, interval_seconds, next_run_at, last_run_at, last_error, created_at`
+
+// UpsertTrackedAccount starts tracking steamid, or changes its interval and next run.
+func (r *sqliteRepo) UpsertTrackedAccount(ctx context.Context, steamid string, interval time.Duration, nextRunAt time.Time) error {
+	const q = `
+INSERT INTO tracked_accounts(steamid, interval_seconds, next_run_at)
+VALUES(?, ?, ?)
+ON CONFLICT(steamid) DO UPDATE SET
+  interval_seconds = excluded.interval_seconds,
+  next_run_at      = excluded.next_run_at;`
+	_, err := r.db.ExecContext(ctx, q, steamid, int64(interval/time.Second), nextRunAt.UTC())
+	return err
+}
+
+func (r *sqliteRepo) DeleteTrackedAccount(ctx context.Context, steamid string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM tracked_accounts WHERE steamid=?;`, steamid)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *sqliteRepo) GetTrackedAccount(ctx context.Context, steamid string) (TrackedAccount, error) {
+	q := `SELECT ` + trackedCols + ` FROM tracked_accounts WHERE steamid=?;`
+	return scanTrackedAccount(r.db.QueryRowContext(ctx, q, steamid))
+}
+
+func (r *sqliteRepo) ListTrackedAccounts(ctx context.Context) ([]TrackedAccount, error) {
+	q := `SELECT ` + trackedCols + ` FROM tracked_accounts ORDER BY next_run_at, steamid;`
+	return r.queryTrackedAccounts(ctx, q)
+}
+
+// ListDueTrackedAccounts returns up to limit accounts whose next run is at or before now,
+// most overdue first.
+func (r *sqliteRepo) ListDueTrackedAccounts(ctx context.Context, now time.Time, limit int) ([]TrackedAccount, error) {
+	q := `SELECT ` + trackedCols + ` FROM tracked_accounts WHERE next_run_at <= ? ORDER BY next_run_at, steamid LIMIT ?;`
+	return r.queryTrackedAccounts(ctx, q, now.UTC(), limit)
+}
+
+func (r *sqliteRepo) queryTrackedAccounts(ctx context.Context, q string, args ...any) ([]TrackedAccount, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []TrackedAccount
+	for rows.Next() {
+		a, err := scanTrackedAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// RecordTrackedRun stores the outcome of a scheduled refresh and when to run next.
+func (r *sqliteRepo) RecordTrackedRun(ctx context.Context, steamid string, ranAt, nextRunAt time.Time, lastError string) error {
+	const q = `UPDATE tracked_accounts SET last_run_at=?, next_run_at=?, last_error=? WHERE steamid=?;`
+	_, err := r.db.ExecContext(ctx, q, ranAt.UTC(), nextRunAt.UTC(), lastError, steamid)
+	return err
+}
+
+func scanTrackedAccount(sc rowScanner) (TrackedAccount, error) {
+	var (
+		a       TrackedAccount
+		secs    int64
+		lastRun sql.NullTime
+	)
+	if err := sc.Scan(&a.SteamID, &secs, &a.NextRunAt, &lastRun, &a.LastError, &a.CreatedAt); err != nil {
+		return TrackedAccount{}, err
+	}
+	a.Interval = time.Duration(secs) * time.Second
+	a.NextRunAt = a.NextRunAt.UTC()
+	a.CreatedAt = a.CreatedAt.UTC()
+	if lastRun.Valid {
+		t := lastRun.Time.UTC()
+		a.LastRunAt = &t
+	}
+	return a, nil
+}
+
+// rowScanner is satisfied by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// sideScanner reads a leading side column into side before the snapshot columns.
+type sideScanner struct {
+	rowScanner
+	side *int
+}
+
+func (s sideScanner) Scan(dest ...any) error {
+	return s.rowScanner.Scan(append([]any{s.side}, dest...)...)
+}
+
+// scanSnapshot reads the column list used by every snapshot SELECT.
+func scanSnapshot(sc rowScanner) (Snapshot, error) {
+	var s Snapshot
+	var synth int
+	if err := sc.Scan(&s.ID, &s.SteamID, &s.AppID, &s.TotalDone, &s.TotalAvailable, &s.CatalogHash, &s.StateHash, &s.TakenAt, &synth); err != nil {
+		return Snapshot{}, err
+	}
+	s.Synthetic = synth == 1
+	return s, nil
+}
+
+// scanAchievementDef reads the column list used by every achievement_catalog SELECT.
+func scanAchievementDef(sc rowScanner) (AchievementDef, error) {
+	var d AchievementDef
+	var hidden int
+	var pct sql.NullFloat64
+	if err := sc.Scan(&d.AppID, &d.APIName, &d.Name, &d.Descr, &d.Icon, &d.IconGray, &hidden, &d.DefaultValue, &pct); err != nil {
+		return AchievementDef{}, err
+	}
+	d.Hidden = hidden == 1
+	if pct.Valid {
+		v := pct.Float64
+		d.GlobalPct = &v
+	}
+	return d, nil
+}
+
+// takenAtArg passes nil for a zero time so SQL falls back to CURRENT_TIMESTAMP.
+func takenAtArg(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
 func boolToInt(b bool) int {
 	if b {
 		return 1
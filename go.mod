@@ -5,6 +5,7 @@ go 1.24.0
 require (
 	github.com/a-h/templ v0.3.960
 	github.com/labstack/echo/v4 v4.12.0
+	modernc.org/sqlite v1.39.1
 )
 
 require (
@@ -27,5 +28,4 @@ require (
 	modernc.org/libc v1.66.10 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
-	modernc.org/sqlite v1.39.1 // indirect
 )
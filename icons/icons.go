@@ -0,0 +1,270 @@
+// Package icons keeps local, content-addressed copies of Steam achievement and game
+// icons under the images dir, so pages don't hotlink Steam's CDN (slow, and it leaks
+// viewer IPs).
+//
+// Files live at <images dir>/icons/<aa>/<sha256>.<ext> and are served by the
+// /images static route. The icon_cache table maps remote URLs to those paths.
+package icons
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/db"
+)
+
+const (
+	subdir           = "icons"
+	urlPrefix        = "/images/"
+	maxIconBytes     = 1 << 20        // Steam icons are a few KB; anything bigger is not an icon
+	retryFailedAfter = 24 * time.Hour // how long a failed download is left alone
+	batchSize        = 500            // URLs fetched per Sync
+)
+
+// Fetcher downloads missing icons and prunes unreferenced ones. Safe for concurrent use.
+type Fetcher struct {
+	repo   db.Repo
+	dir    string // images dir (served at /images)
+	client *http.Client
+	kick   chan struct{}
+	mu     sync.Mutex // serialises Sync
+}
+
+// SyncStats reports what one Sync did.
+type SyncStats struct {
+	Fetched     int   // icons downloaded and recorded
+	Failed      int   // downloads that failed (retried after a day)
+	PrunedRows  int64 // icon_cache rows no longer referenced
+	PrunedFiles int   // files removed from disk
+}
+
+// New returns a fetcher storing files under dir (the images dir).
+func New(repo db.Repo, dir string) *Fetcher {
+	return &Fetcher{
+		repo:   repo,
+		dir:    dir,
+		client: &http.Client{Timeout: 15 * time.Second},
+		kick:   make(chan struct{}, 1),
+	}
+}
+
+// Run syncs immediately, then every interval and whenever Kick is called,
+// until ctx is done. interval <= 0 disables the background sync.
+func (f *Fetcher) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if st, err := f.Sync(ctx); err != nil {
+			log.Printf("icons: sync: %v", err)
+		} else if st != (SyncStats{}) {
+			log.Printf("icons: fetched=%d failed=%d pruned_rows=%d pruned_files=%d",
+				st.Fetched, st.Failed, st.PrunedRows, st.PrunedFiles)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-f.kick:
+		}
+	}
+}
+
+// Kick asks Run to sync soon (e.g. after a refresh added new games). Never blocks.
+func (f *Fetcher) Kick() {
+	if f == nil {
+		return
+	}
+	select {
+	case f.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Sync downloads referenced icons that have no local copy, then prunes rows and
+// files no longer referenced by achievement_catalog or games.
+// A failed download is recorded and doesn't stop the others.
+func (f *Fetcher) Sync(ctx context.Context) (SyncStats, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var st SyncStats
+	now := time.Now().UTC()
+	urls, err := f.repo.ListUncachedIconURLs(ctx, now.Add(-retryFailedAfter), batchSize)
+	if err != nil {
+		return st, err
+	}
+	for _, u := range urls {
+		if ctx.Err() != nil {
+			return st, ctx.Err()
+		}
+		e := db.IconCacheEntry{URL: u, FetchedAt: now}
+		if e.Path, err = f.fetch(ctx, u); err != nil {
+			e.LastError = err.Error()
+			st.Failed++
+		} else {
+			st.Fetched++
+		}
+		if err := f.repo.PutIconCache(ctx, e); err != nil {
+			return st, err
+		}
+	}
+
+	if st.PrunedRows, err = f.repo.PruneIconCache(ctx); err != nil {
+		return st, err
+	}
+	st.PrunedFiles, err = f.pruneFiles(ctx)
+	return st, err
+}
+
+// fetch downloads u and stores it content-addressed. Returns the path relative to dir.
+func (f *Fetcher) fetch(ctx context.Context, u string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: HTTP %d", u, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxIconBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > maxIconBytes {
+		return "", fmt.Errorf("GET %s: larger than %d bytes", u, maxIconBytes)
+	}
+	ext, err := imageExt(body)
+	if err != nil {
+		return "", fmt.Errorf("GET %s: %w", u, err)
+	}
+
+	sum := sha256.Sum256(body)
+	name := hex.EncodeToString(sum[:])
+	rel := path.Join(subdir, name[:2], name+ext)
+	full := filepath.Join(f.dir, filepath.FromSlash(rel))
+	if _, err := os.Stat(full); err == nil {
+		return rel, nil // same bytes already stored (another URL, or a re-fetch)
+	}
+	if err := writeAtomic(full, body); err != nil {
+		return "", err
+	}
+	return rel, nil
+}
+
+// pruneFiles deletes files under dir/icons that no icon_cache row points at.
+func (f *Fetcher) pruneFiles(ctx context.Context) (int, error) {
+	paths, err := f.repo.ListIconPaths(ctx)
+	if err != nil {
+		return 0, err
+	}
+	keep := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		keep[p] = true
+	}
+
+	root := filepath.Join(f.dir, subdir)
+	removed := 0
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(f.dir, p)
+		if err != nil {
+			return err
+		}
+		if keep[filepath.ToSlash(rel)] {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// LocalURL is the URL a cached icon path is served at.
+func LocalURL(rel string) string {
+	return urlPrefix + rel
+}
+
+// Localize maps remote icon URLs to their local /images URLs.
+// URLs without a local copy yet are absent from the result.
+func Localize(ctx context.Context, repo db.Repo, urls []string) (map[string]string, error) {
+	paths, err := repo.GetIconPaths(ctx, urls)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string, len(paths))
+	for u, p := range paths {
+		out[u] = LocalURL(p)
+	}
+	return out, nil
+}
+
+// imageExt sniffs the file type; anything that isn't an image is rejected.
+func imageExt(body []byte) (string, error) {
+	switch ct := http.DetectContentType(body); ct {
+	case "image/jpeg":
+		return ".jpg", nil
+	case "image/png":
+		return ".png", nil
+	case "image/gif":
+		return ".gif", nil
+	case "image/webp":
+		return ".webp", nil
+	default:
+		return "", fmt.Errorf("not an image (%s)", ct)
+	}
+}
+
+// writeAtomic writes body to a temp file next to full and renames it into place.
+func writeAtomic(full string, body []byte) error {
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(full), ".icon-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(body); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), full)
+}
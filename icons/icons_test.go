@@ -0,0 +1,214 @@
+package icons
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/db"
+)
+
+func openTestDB(t *testing.T) (*sql.DB, db.Repo) {
+	t.Helper()
+	sqlDB, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.ApplyMigrations(context.Background(), sqlDB, db.Migrations()); err != nil {
+		t.Fatal(err)
+	}
+	return sqlDB, db.NewRepo(sqlDB)
+}
+
+func pngBytes(t *testing.T, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// iconServer serves files by path and counts requests; paths not in files are 404.
+type iconServer struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	hits  map[string]int
+}
+
+func (s *iconServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hits[r.URL.Path]++
+	b, ok := s.files[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	_, _ = w.Write(b)
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	sqlDB, repo := openTestDB(t)
+
+	red, blue := pngBytes(t, color.RGBA{R: 255, A: 255}), pngBytes(t, color.RGBA{B: 255, A: 255})
+	srv := &iconServer{
+		files: map[string][]byte{
+			"/red.png":       red,
+			"/red-again.png": red, // same bytes under another URL
+			"/blue.png":      blue,
+			"/game.png":      blue,
+			"/text.png":      []byte("hello, not an image"),
+		},
+		hits: map[string]int{},
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	if err := repo.UpsertGame(ctx, db.Game{AppID: 10, Name: "Game", IconURL: ts.URL + "/game.png"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpsertAchievementDefs(ctx, []db.AchievementDef{
+		{AppID: 10, APIName: "A", Name: "A", Icon: ts.URL + "/red.png", IconGray: ts.URL + "/red-again.png"},
+		{AppID: 10, APIName: "B", Name: "B", Icon: ts.URL + "/blue.png", IconGray: ts.URL + "/missing.png"},
+		{AppID: 10, APIName: "C", Name: "C", Icon: ts.URL + "/text.png"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	f := New(repo, dir)
+	st, err := f.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Fetched != 4 || st.Failed != 2 {
+		t.Errorf("first sync = %+v, want 4 fetched, 2 failed", st)
+	}
+
+	urls := []string{ts.URL + "/red.png", ts.URL + "/red-again.png", ts.URL + "/blue.png", ts.URL + "/game.png", ts.URL + "/missing.png", ts.URL + "/text.png"}
+	paths, err := repo.GetIconPaths(ctx, urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("content addressed", func(t *testing.T) {
+		p := paths[ts.URL+"/red.png"]
+		if filepath.Ext(p) != ".png" || filepath.Dir(filepath.Dir(p)) != subdir {
+			t.Fatalf("path = %q, want icons/<aa>/<sha256>.png", p)
+		}
+		b, err := os.ReadFile(filepath.Join(dir, p))
+		if err != nil || !bytes.Equal(b, red) {
+			t.Errorf("stored file differs from the download (err %v)", err)
+		}
+		if got := LocalURL(p); got != "/images/"+p {
+			t.Errorf("LocalURL = %q", got)
+		}
+	})
+
+	t.Run("identical bytes are stored once", func(t *testing.T) {
+		if paths[ts.URL+"/red.png"] != paths[ts.URL+"/red-again.png"] {
+			t.Errorf("same bytes got different paths: %v", paths)
+		}
+		if paths[ts.URL+"/blue.png"] != paths[ts.URL+"/game.png"] {
+			t.Errorf("game and achievement icon with the same bytes got different paths: %v", paths)
+		}
+		if n := countFiles(t, dir); n != 2 {
+			t.Errorf("%d files on disk, want 2", n)
+		}
+	})
+
+	t.Run("failed downloads stay uncached", func(t *testing.T) {
+		for _, u := range []string{ts.URL + "/missing.png", ts.URL + "/text.png"} {
+			if p, ok := paths[u]; ok {
+				t.Errorf("%s cached at %q after a failed download", u, p)
+			}
+		}
+		local, err := Localize(ctx, repo, urls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(local) != 4 {
+			t.Errorf("Localize = %v, want the 4 downloaded icons only", local)
+		}
+	})
+
+	t.Run("second sync fetches nothing", func(t *testing.T) {
+		// Failures are left alone for a day; successes are never re-fetched.
+		st, err := f.Sync(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st != (SyncStats{}) {
+			t.Errorf("second sync = %+v, want nothing to do", st)
+		}
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		for p, n := range srv.hits {
+			if n != 1 {
+				t.Errorf("%s requested %d times, want 1", p, n)
+			}
+		}
+	})
+
+	t.Run("prune rows and files", func(t *testing.T) {
+		stray := filepath.Join(dir, subdir, "zz", "stray.jpg")
+		if err := os.MkdirAll(filepath.Dir(stray), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(stray, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// Drop the achievements using red; the blue file is still used by the game.
+		if _, err := sqlDB.ExecContext(ctx, `UPDATE achievement_catalog SET icon='', icon_gray='' WHERE apiname IN ('A','B')`); err != nil {
+			t.Fatal(err)
+		}
+
+		st, err := f.Sync(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// red, red-again, blue and missing lose their last reference.
+		if st.PrunedRows != 4 || st.PrunedFiles != 2 {
+			t.Errorf("prune = %+v, want 4 rows and 2 files (red + stray)", st)
+		}
+		if _, err := os.Stat(filepath.Join(dir, paths[ts.URL+"/red.png"])); !os.IsNotExist(err) {
+			t.Errorf("unreferenced file still on disk (err %v)", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, paths[ts.URL+"/game.png"])); err != nil {
+			t.Errorf("file still used by the game was removed: %v", err)
+		}
+	})
+}
+
+func countFiles(t *testing.T, dir string) int {
+	t.Helper()
+	n := 0
+	err := filepath.WalkDir(filepath.Join(dir, subdir), func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -0,0 +1,228 @@
+// Package jobs runs account refreshes in the background so HTTP requests return
+// at once. Each job has an ID, live RefreshStats, and can be cancelled; finished
+// jobs are kept in memory for a while so clients can read the outcome.
+//
+// There is at most one running job per SteamID: whoever asks for a refresh while
+// one is in flight (another tab, the scheduler) joins it and gets its result.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/service"
+)
+
+// keepFinished is how long a finished job stays readable.
+const keepFinished = 15 * time.Minute
+
+// State is where a job is in its life.
+type State string
+
+const (
+	Running  State = "running"
+	Done     State = "done"
+	Failed   State = "failed"
+	Canceled State = "canceled"
+)
+
+// RunFunc performs the refresh for steamid, reporting progress as it goes.
+type RunFunc func(ctx context.Context, steamid string, progress service.ProgressFunc) (service.RefreshStats, error)
+
+// AdmitFunc decides whether a new run for steamid may start; an error refuses it
+// and is returned from Start as is. It is only asked when no run for steamid is
+// in flight, and never concurrently for the same steamid. If the admitted run
+// then fails or is cancelled, undo (when non-nil) is called to give the admission back.
+type AdmitFunc func(ctx context.Context, steamid string) (undo func(), err error)
+
+// Job is a point-in-time copy of a refresh job.
+type Job struct {
+	ID         string
+	SteamID    string
+	State      State
+	Stats      service.RefreshStats
+	Error      string // set when State is Failed
+	StartedAt  time.Time
+	FinishedAt *time.Time // nil while running
+}
+
+// Finished reports whether the job has stopped, for any reason.
+func (j Job) Finished() bool { return j.State != Running }
+
+type job struct {
+	Job
+	err     error              // RunFunc's error, for callers that wait
+	cancel  context.CancelFunc // cancels the run
+	changed chan struct{}      // closed (and replaced) on every update
+}
+
+// Manager owns the running and recently finished jobs. Safe for concurrent use.
+type Manager struct {
+	run   RunFunc
+	admit AdmitFunc // nil admits everything
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	running map[string]*job // steamid -> its running job, or one being admitted (no ID yet)
+}
+
+// New returns a manager whose jobs call run, once admit (if non-nil) allows them.
+func New(run RunFunc, admit AdmitFunc) *Manager {
+	return &Manager{
+		run:     run,
+		admit:   admit,
+		jobs:    map[string]*job{},
+		running: map[string]*job{},
+	}
+}
+
+// Start launches a refresh of steamid in the background and returns it.
+// If one is already running for steamid, that job is returned with started=false.
+// Otherwise the admit check runs first; its error is returned if it refuses.
+// ctx only bounds the admit check, not the job.
+func (m *Manager) Start(ctx context.Context, steamid string) (j Job, started bool, err error) {
+	m.mu.Lock()
+	m.forgetOld(time.Now())
+	for {
+		jb, ok := m.running[steamid]
+		if !ok {
+			break
+		}
+		if jb.ID != "" {
+			m.mu.Unlock()
+			return jb.Job, false, nil
+		}
+		// Someone else is being admitted; wait to see how that goes.
+		changed := jb.changed
+		m.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return Job{}, false, ctx.Err()
+		case <-changed:
+		}
+		m.mu.Lock()
+	}
+
+	// Reserve steamid so no one else is admitted meanwhile, then ask admit
+	// without holding mu: it may go to the database.
+	jb := &job{
+		Job:     Job{SteamID: steamid, State: Running},
+		changed: make(chan struct{}),
+	}
+	m.running[steamid] = jb
+	m.mu.Unlock()
+
+	var undo func()
+	if m.admit != nil {
+		undo, err = m.admit(ctx, steamid)
+	}
+	if err != nil {
+		m.update(jb, func() { delete(m.running, steamid) })
+		return Job{}, false, err
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	m.update(jb, func() {
+		jb.ID, jb.StartedAt, jb.cancel = newID(), time.Now().UTC(), cancel
+		m.jobs[jb.ID] = jb
+		j = jb.Job
+	})
+
+	go func() {
+		defer cancel()
+		stats, err := m.run(runCtx, steamid, func(st service.RefreshStats) {
+			m.update(jb, func() { jb.Stats = st })
+		})
+		if err != nil && undo != nil {
+			undo()
+		}
+		m.update(jb, func() {
+			now := time.Now().UTC()
+			jb.Stats, jb.err, jb.FinishedAt = stats, err, &now
+			switch {
+			case err == nil:
+				jb.State = Done
+			case errors.Is(err, context.Canceled):
+				jb.State = Canceled
+			default:
+				jb.State, jb.Error = Failed, err.Error()
+			}
+			delete(m.running, steamid)
+		})
+	}()
+	return j, true, nil
+}
+
+// Get returns the job with id, plus a channel closed the next time it changes.
+func (m *Manager) Get(id string) (Job, <-chan struct{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jb, ok := m.jobs[id]
+	if !ok {
+		return Job{}, nil, false
+	}
+	return jb.Job, jb.changed, true
+}
+
+// Cancel stops a running job. It reports false if there is no such job;
+// cancelling a finished job is a no-op.
+func (m *Manager) Cancel(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jb, ok := m.jobs[id]
+	if ok && jb.State == Running {
+		jb.cancel()
+	}
+	return ok
+}
+
+// Wait blocks until the job finishes or ctx is done, and returns the job with
+// the error its run returned.
+func (m *Manager) Wait(ctx context.Context, id string) (Job, error) {
+	for {
+		m.mu.Lock()
+		jb, ok := m.jobs[id]
+		if !ok {
+			m.mu.Unlock()
+			return Job{}, errors.New("job not found")
+		}
+		j, err, changed := jb.Job, jb.err, jb.changed
+		m.mu.Unlock()
+		if j.Finished() {
+			return j, err
+		}
+		select {
+		case <-ctx.Done():
+			return j, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// update applies fn to jb under the lock and wakes everyone watching it.
+func (m *Manager) update(jb *job, fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn()
+	close(jb.changed)
+	jb.changed = make(chan struct{})
+}
+
+// forgetOld drops jobs that finished more than keepFinished ago. Caller holds mu.
+func (m *Manager) forgetOld(now time.Time) {
+	for id, jb := range m.jobs {
+		if jb.FinishedAt != nil && now.Sub(*jb.FinishedAt) > keepFinished {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
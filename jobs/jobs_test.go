@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/service"
+)
+
+func TestAdmitRunsOutsideTheLock(t *testing.T) {
+	admitting, release := make(chan struct{}), make(chan struct{})
+	var admits atomic.Int32
+	m := New(func(ctx context.Context, steamid string, _ service.ProgressFunc) (service.RefreshStats, error) {
+		<-ctx.Done()
+		return service.RefreshStats{}, ctx.Err()
+	}, func(ctx context.Context, steamid string) (func(), error) {
+		admits.Add(1)
+		if steamid == "slow" {
+			close(admitting)
+			<-release
+		}
+		return nil, nil
+	})
+	ctx := context.Background()
+
+	other, _, err := m.Start(ctx, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := make(chan Job)
+	go func() {
+		j, _, _ := m.Start(ctx, "slow")
+		first <- j
+	}()
+	<-admitting
+
+	// Other jobs stay readable while "slow" is being admitted.
+	done := make(chan struct{})
+	go func() {
+		m.Get(other.ID)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Get blocked behind admit")
+	}
+
+	// A second caller for the same account waits for the admission and joins.
+	second := make(chan Job)
+	go func() {
+		j, started, _ := m.Start(ctx, "slow")
+		if started {
+			t.Error("second Start started a run")
+		}
+		second <- j
+	}()
+	close(release)
+	j1, j2 := <-first, <-second
+	if j1.ID == "" || j1.ID != j2.ID {
+		t.Errorf("jobs %q and %q, want the same one", j1.ID, j2.ID)
+	}
+	if n := admits.Load(); n != 2 {
+		t.Errorf("admit called %d times, want 2", n)
+	}
+	m.Cancel(j1.ID)
+	m.Cancel(other.ID)
+}
+
+func TestAdmitRefusedRollsBack(t *testing.T) {
+	refuse := errors.New("throttled")
+	var admits atomic.Int32
+	m := New(func(ctx context.Context, steamid string, _ service.ProgressFunc) (service.RefreshStats, error) {
+		return service.RefreshStats{}, nil
+	}, func(ctx context.Context, steamid string) (func(), error) {
+		if admits.Add(1) == 1 {
+			return nil, refuse
+		}
+		return nil, nil
+	})
+	ctx := context.Background()
+	if _, _, err := m.Start(ctx, "a"); !errors.Is(err, refuse) {
+		t.Fatalf("err = %v, want the admit error", err)
+	}
+	j, started, err := m.Start(ctx, "a")
+	if err != nil || !started {
+		t.Fatalf("second Start = %v, %v; want a new run", started, err)
+	}
+	if j, err := m.Wait(ctx, j.ID); err != nil || j.State != Done {
+		t.Errorf("job %+v, %v; want done", j, err)
+	}
+}
+
+func TestUndoOnFailure(t *testing.T) {
+	boom := errors.New("boom")
+	for _, tt := range []struct {
+		name  string
+		err   error
+		state State
+		undo  bool
+	}{
+		{"done", nil, Done, false},
+		{"failed", boom, Failed, true},
+		{"canceled", context.Canceled, Canceled, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var undone atomic.Bool
+			m := New(func(ctx context.Context, steamid string, _ service.ProgressFunc) (service.RefreshStats, error) {
+				return service.RefreshStats{}, tt.err
+			}, func(ctx context.Context, steamid string) (func(), error) {
+				return func() { undone.Store(true) }, nil
+			})
+			ctx := context.Background()
+			j, _, err := m.Start(ctx, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			j, _ = m.Wait(ctx, j.ID)
+			if j.State != tt.state {
+				t.Errorf("state = %s, want %s", j.State, tt.state)
+			}
+			if undone.Load() != tt.undo {
+				t.Errorf("undo called = %v, want %v", undone.Load(), tt.undo)
+			}
+		})
+	}
+}
@@ -4,47 +4,81 @@ import (
 	"context"
 	"database/sql"
 	"log"
+	"path/filepath"
 	"time"
 
+	"github.com/James-Wolfley/steam-achievement-tracker/config"
 	dbpkg "github.com/James-Wolfley/steam-achievement-tracker/db"
+	"github.com/James-Wolfley/steam-achievement-tracker/icons"
+	"github.com/James-Wolfley/steam-achievement-tracker/jobs"
+	"github.com/James-Wolfley/steam-achievement-tracker/scheduler"
+	"github.com/James-Wolfley/steam-achievement-tracker/steamapi"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 )
 
 func main() {
-	// 1) Open DB + apply migrations
-	sqlDB, err := dbpkg.Open("data/app.db")
+	// 1) Open DB + apply migrations. The DB and the icon cache both live under
+	// the data dir, never relative to wherever the binary was started.
+	dataDir := config.DataDir()
+	imagesDir := filepath.Join(dataDir, "images")
+	sqlDB, err := dbpkg.Open(filepath.Join(dataDir, "app.db"))
 	if err != nil {
 		log.Fatalf("open db: %v", err)
 	}
 	defer func(db *sql.DB) { _ = db.Close() }(sqlDB)
 
+	static, migrations := assetFS()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if err := dbpkg.ApplyMigrations(ctx, sqlDB, "db/migrations"); err != nil {
+	if err := dbpkg.ApplyMigrations(ctx, sqlDB, migrations); err != nil {
 		log.Fatalf("migrate: %v", err)
 	}
 
 	// 2) Repo + app container
 	repo := dbpkg.NewRepo(sqlDB)
-	app := &Application{DB: sqlDB, Repo: repo}
+	app := &Application{DB: sqlDB, Repo: repo, Icons: icons.New(repo, imagesDir)}
+	if app.Steam, err = steamapi.New(); err != nil {
+		log.Printf("steam: %v; custom URL names can't be resolved", err)
+	}
+
+	app.Jobs = jobs.New(app.runRefresh, app.claimRefresh)
+	app.Scheduler = scheduler.New(repo, steamapi.SharedLimiter(), app.refreshTracked)
+
+	// Local icon cache (files under <data dir>/images/icons, served by the /images route)
+	go app.Icons.Run(context.Background(), config.IconSyncInterval())
+	// Periodic refreshes of tracked accounts (schedule kept in tracked_accounts)
+	go app.Scheduler.Run(context.Background(), config.SchedulerTick())
 
 	// 3) Echo
 	server := echo.New()
 	server.Use(middleware.Logger())
 	server.Use(middleware.Recover())
 
-	server.Static("/css", "css")
-	server.Static("/images", "images")
-	server.Static("/scripts", "scripts")
+	server.StaticFS("/css", echo.MustSubFS(static, "css"))
+	server.StaticFS("/scripts", echo.MustSubFS(static, "scripts"))
+	server.Static("/images", imagesDir) // runtime icon cache, always on disk
 
 	server.GET("/", app.Home)
 	server.GET("/ui/results", app.UIResults)
 	server.POST("/ui/refresh", app.UIRefresh)
+	server.GET("/ui/games/:appid", app.UIGame)
+	server.GET("/ui/jobs/:id", app.UIJob)
+	server.POST("/ui/jobs/:id/cancel", app.UICancelJob)
 
 	server.GET("/api/results/:steamid", app.APIResults)
-	server.GET("/export/:steamid.csv", app.ExportCSV)
+	server.GET("/api/games/:appid", app.APIGame)
+	server.GET("/api/summary/:steamid", app.APISummary)
+	server.GET("/export/:steamid", app.ExportCSV) // /export/<steamid>.csv
 	server.POST("/api/refresh/:steamid", app.Refresh)
+	server.GET("/api/jobs/:id", app.APIJob)
+	server.GET("/api/jobs/:id/events", app.JobEvents)
+	server.DELETE("/api/jobs/:id", app.CancelJob)
+	server.POST("/api/backfill/:steamid", app.Backfill)
+	server.GET("/api/steam/budget", app.SteamBudget)
+	server.GET("/api/tracked", app.ListTracked)
+	server.POST("/api/tracked/:steamid", app.Track)
+	server.DELETE("/api/tracked/:steamid", app.Untrack)
 
 	server.Logger.Fatal(server.Start(":8080"))
 }
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/config"
+	"github.com/James-Wolfley/steam-achievement-tracker/jobs"
+	"github.com/James-Wolfley/steam-achievement-tracker/service"
+	"github.com/James-Wolfley/steam-achievement-tracker/steamapi"
+)
+
+// Every refresh, whichever route or the scheduler asked for it, goes through
+// here: requestRefresh resolves the account and starts (or joins) its job,
+// claimRefresh owns the throttle window, runRefresh does the work and the
+// bookkeeping, and refreshErrStatus maps failures to HTTP statuses.
+
+// refreshRequest is the outcome of asking for a refresh. The JSON and HTML
+// routes render the same outcomes from it.
+type refreshRequest struct {
+	Job        jobs.Job
+	Started    bool  // false: joined the refresh already running for the account
+	Err        error // nil when a job was started or joined
+	Status     int   // HTTP status for Err
+	RetryAfter int   // seconds until the throttle window ends; set with 429
+}
+
+// requestRefresh starts a background refresh of the account raw names (any
+// form resolveSteamID accepts), or joins the one already running.
+func (app *Application) requestRefresh(ctx context.Context, raw string) refreshRequest {
+	if raw == "" {
+		return refreshRequest{Err: errors.New("missing steamid"), Status: http.StatusBadRequest}
+	}
+	steamid, status, err := app.resolveSteamID(ctx, raw)
+	if err != nil {
+		return refreshRequest{Err: err, Status: status}
+	}
+	j, started, err := app.Jobs.Start(ctx, steamid)
+	var te *throttledError
+	if errors.As(err, &te) {
+		return refreshRequest{Err: err, Status: http.StatusTooManyRequests, RetryAfter: te.retrySeconds()}
+	}
+	if err != nil {
+		return refreshRequest{Err: err, Status: http.StatusInternalServerError}
+	}
+	return refreshRequest{Job: j, Started: started, Status: http.StatusAccepted}
+}
+
+// runRefresh is the jobs.RunFunc behind every refresh: fetch from Steam and
+// nudge the icon cache. The throttle gate was stamped when claimRefresh admitted it.
+func (app *Application) runRefresh(ctx context.Context, steamid string, progress service.ProgressFunc) (service.RefreshStats, error) {
+	client, err := steamapi.New()
+	if err != nil {
+		return service.RefreshStats{}, err
+	}
+	stats, err := service.RefreshUserWithProgress(ctx, app.Repo, client, steamid, config.RefreshWorkers(), progress)
+	if err != nil {
+		return stats, err
+	}
+	app.Icons.Kick() // fetch icons for any new games/achievements
+	return stats, nil
+}
+
+// throttledError refuses a refresh that falls inside the throttle window.
+type throttledError struct {
+	retryAfter time.Duration
+}
+
+func (e *throttledError) Error() string {
+	return fmt.Sprintf("refreshed too recently, retry in %s", e.retryAfter.Round(time.Second))
+}
+
+// retrySeconds rounds the wait up, for Retry-After headers.
+func (e *throttledError) retrySeconds() int {
+	return int((e.retryAfter + time.Second - 1) / time.Second)
+}
+
+// claimRefresh is the jobs.AdmitFunc: it claims steamid's throttle gate, or
+// returns a *throttledError if the window hasn't passed. Undoing the claim puts
+// the gate back, so a failed or cancelled refresh doesn't lock the account out.
+func (app *Application) claimRefresh(ctx context.Context, steamid string) (func(), error) {
+	tw := config.ThrottleWindow()
+	if tw <= 0 {
+		return nil, nil
+	}
+	now := time.Now().UTC()
+	ok, last, err := app.Repo.TryAcquireRefresh(ctx, steamid, now, tw)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &throttledError{retryAfter: last.Add(tw).Sub(now)}
+	}
+	return func() {
+		if err := app.Repo.ReleaseRefresh(context.Background(), steamid, now, last); err != nil {
+			log.Printf("refresh %s: release throttle gate: %v", steamid, err)
+		}
+	}, nil
+}
+
+// refreshTracked is the scheduler's RefreshFunc. It goes through the job manager,
+// so a scheduled run and a button press never refresh the same account twice.
+func (app *Application) refreshTracked(ctx context.Context, steamid string) error {
+	j, _, err := app.Jobs.Start(ctx, steamid)
+	var te *throttledError
+	if errors.As(err, &te) {
+		return nil // refreshed by someone else moments ago, which is all we wanted
+	}
+	if err != nil {
+		return err
+	}
+	_, err = app.Jobs.Wait(ctx, j.ID)
+	return err
+}
+
+// refreshErrStatus maps a refresh failure to an HTTP status.
+func refreshErrStatus(err error) int {
+	switch {
+	case errors.Is(err, steamapi.ErrBudgetExhausted):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, steamapi.ErrNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -1,16 +1,23 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/James-Wolfley/steam-achievement-tracker/config"
 	"github.com/James-Wolfley/steam-achievement-tracker/db"
+	"github.com/James-Wolfley/steam-achievement-tracker/scheduler"
 	"github.com/James-Wolfley/steam-achievement-tracker/service"
 	"github.com/James-Wolfley/steam-achievement-tracker/steamapi"
+	"github.com/James-Wolfley/steam-achievement-tracker/steamid"
 	"github.com/James-Wolfley/steam-achievement-tracker/views"
+	"github.com/a-h/templ"
 	"github.com/labstack/echo/v4"
 )
 
@@ -18,28 +25,57 @@ func (app *Application) Home(c echo.Context) error {
 	return render(c, http.StatusOK, views.Home())
 }
 
-// GET /api/results/:steamid
-// Returns the ready-to-render comparison rows for all games with snapshots.
+// GET /api/results/:steamid[?spoilers=1][&from=...&to=...|&from_id=N&to_id=M]
+// Returns the player profile (if known) and the ready-to-render comparison rows
+// for all games with snapshots. private=true means Steam hid the data.
 func (app *Application) APIResults(c echo.Context) error {
-	steamid := c.Param("steamid")
 	ctx := c.Request().Context()
+	steamid, status, err := app.resolveSteamID(ctx, c.Param("steamid"))
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
 
-	rows, err := service.BuildAllComparisonsForUser(ctx, app.Repo, steamid)
+	opts, err := compareOptions(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	rows, err := service.BuildAllComparisonsForUser(ctx, app.Repo, steamid, opts)
+	if err != nil {
+		return c.JSON(compareErrStatus(err), map[string]string{"error": err.Error()})
+	}
+	player, err := service.LoadPlayer(ctx, app.Repo, steamid)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-	return c.JSON(http.StatusOK, rows)
+	resp := map[string]any{
+		"steamid": steamid,
+		"player":  player, // null until the first refresh
+		"private": service.ProfilePrivate(player),
+		"rows":    rows,
+	}
+	if service.ProfilePrivate(player) {
+		resp["message"] = service.PrivateProfileMessage
+	}
+	return c.JSON(http.StatusOK, resp)
 }
 
-// GET /export/:steamid.csv
+// GET /export/:steamid.csv[?from=...&to=...]
 // Streams a CSV with header + rows (may be header-only if no snapshots exist).
+// Registered as /export/:steamid since echo param names run to the next '/'.
 func (app *Application) ExportCSV(c echo.Context) error {
-	steamid := c.Param("steamid")
 	ctx := c.Request().Context()
+	steamid, status, err := app.resolveSteamID(ctx, strings.TrimSuffix(c.Param("steamid"), ".csv"))
+	if err != nil {
+		return c.String(status, err.Error())
+	}
 
-	rows, err := service.BuildAllComparisonsForUser(ctx, app.Repo, steamid)
+	opts, err := compareOptions(c)
 	if err != nil {
-		return c.String(http.StatusInternalServerError, err.Error())
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	rows, err := service.BuildAllComparisonsForUser(ctx, app.Repo, steamid, opts)
+	if err != nil {
+		return c.String(compareErrStatus(err), err.Error())
 	}
 
 	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
@@ -53,90 +89,465 @@ func (app *Application) ExportCSV(c echo.Context) error {
 	return nil
 }
 
-// POST /api/refresh/:steamid
-// Triggers a refresh from Steam with throttling.
-// - 200: { ok: true, gamesVisited, snapshots }
+// POST /api/refresh/:steamid[?wait=1]
+// Starts a background refresh job (or returns the one already running) with throttling.
+// - 202: { ok: true, job: {...}, started, status_url, events_url }
+// - 200 with wait=1: blocks until done and returns the refresh stats
 // - 429: { error: "throttled", retry_after_seconds: N } + Retry-After header
 func (app *Application) Refresh(c echo.Context) error {
-	steamid := c.Param("steamid")
 	ctx := c.Request().Context()
-
-	client, err := steamapi.New()
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+	req := app.requestRefresh(ctx, c.Param("steamid"))
+	setRetryAfter(c, req)
+	if req.RetryAfter > 0 {
+		return c.JSON(req.Status, map[string]any{
+			"error":               "throttled",
+			"retry_after_seconds": req.RetryAfter,
+		})
+	}
+	if req.Err != nil {
+		return c.JSON(req.Status, map[string]any{"error": req.Err.Error()})
 	}
 
-	// Throttle gate first (unchanged)
-	if tw := config.ThrottleWindow(); tw > 0 {
-		last, err := app.Repo.GetLastRefreshAt(ctx, steamid)
-		if err == nil && !last.IsZero() {
-			if remain := tw - time.Since(last); remain > 0 {
-				sec := int((remain + time.Second - 1) / time.Second)
-				c.Response().Header().Set("Retry-After", fmt.Sprintf("%d", sec))
-				return c.JSON(http.StatusTooManyRequests, map[string]any{
-					"error":               "throttled",
-					"retry_after_seconds": sec,
-				})
-			}
-		} else if err != nil && !errors.Is(err, db.ErrNoRows) {
-			return c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
-		}
+	j := req.Job
+	if c.QueryParam("wait") != "1" {
+		return c.JSON(http.StatusAccepted, map[string]any{
+			"ok":         true,
+			"job":        j,
+			"started":    req.Started, // false: a refresh for this account was already running
+			"status_url": "/api/jobs/" + j.ID,
+			"events_url": "/api/jobs/" + j.ID + "/events",
+		})
 	}
 
-	// Always concurrent with configured worker count
-	workers := config.RefreshWorkers()
-	stats, err := service.RefreshUserConcurrent(ctx, app.Repo, client, steamid, workers)
+	j, err := app.Jobs.Wait(ctx, j.ID)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+		return c.JSON(refreshErrStatus(err), map[string]any{"error": err.Error()})
 	}
-
-	// Update throttle timestamp
-	if err := app.Repo.SetLastRefreshNow(ctx, steamid, time.Now().UTC()); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+	stats := j.Stats
+	if stats.Private {
+		return c.JSON(http.StatusOK, map[string]any{
+			"ok":      true,
+			"private": true,
+			"message": service.PrivateProfileMessage,
+		})
 	}
 
 	return c.JSON(http.StatusOK, map[string]any{
 		"ok":            true,
-		"workers":       workers, // or config.RefreshWorkers()
+		"workers":       config.RefreshWorkers(),
 		"owned":         stats.Owned,
 		"queued":        stats.Queued,
 		"checked":       stats.Checked,
 		"updated":       stats.Updated,
 		"skipped":       stats.Skipped,
 		"skippedCached": stats.SkippedCached,
+		"failed":        stats.Failed,
+		"failures":      failuresJSON(stats.Failures),
 		"snapshots":     stats.Snapshots, // same as updated
+		"pruned":        stats.Pruned.Snapshots,
+		"prunedRows":    stats.Pruned.Rows(),
+	})
+}
+
+// failuresJSON lists the games a refresh couldn't read ([] rather than null).
+func failuresJSON(fs []service.GameFailure) []map[string]any {
+	out := make([]map[string]any, 0, len(fs))
+	for _, f := range fs {
+		out = append(out, map[string]any{
+			"appid": f.AppID,
+			"name":  f.Name,
+			"step":  f.Step,
+			"kind":  f.Kind,
+			"error": f.Error,
+		})
+	}
+	return out
+}
+
+// GET /api/jobs/:id
+// Current state and live stats of a refresh job (404 once forgotten).
+func (app *Application) APIJob(c echo.Context) error {
+	j, _, ok := app.Jobs.Get(c.Param("id"))
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "job not found"})
+	}
+	return c.JSON(http.StatusOK, j)
+}
+
+// DELETE /api/jobs/:id
+// Cancels a running job; the job then finishes with state "canceled".
+func (app *Application) CancelJob(c echo.Context) error {
+	if !app.Jobs.Cancel(c.Param("id")) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "job not found"})
+	}
+	return c.JSON(http.StatusAccepted, map[string]any{"ok": true})
+}
+
+// sseMinGap limits progress events to a few per second; refreshes report per game.
+const sseMinGap = 250 * time.Millisecond
+
+// GET /api/jobs/:id/events
+// Server-Sent Events: a "progress" event with the job JSON on every change
+// (at most every sseMinGap), then one "done" event when it finishes.
+func (app *Application) JobEvents(c echo.Context) error {
+	id := c.Param("id")
+	if _, _, ok := app.Jobs.Get(id); !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "job not found"})
+	}
+	ctx := c.Request().Context()
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.Header().Set(echo.HeaderConnection, "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		j, changed, ok := app.Jobs.Get(id)
+		if !ok {
+			return nil
+		}
+		event := "progress"
+		if j.Finished() {
+			event = "done"
+		}
+		data, err := json.Marshal(j)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+			return nil // client went away
+		}
+		w.Flush()
+		if j.Finished() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(sseMinGap):
+		}
+	}
+}
+
+// POST /api/backfill/:steamid?mode=daily|unlock
+// Rebuilds synthetic (flagged) history from stored unlock times. Safe to re-run.
+func (app *Application) Backfill(c echo.Context) error {
+	steamid, status, err := app.resolveSteamID(c.Request().Context(), c.Param("steamid"))
+	if err != nil {
+		return c.JSON(status, map[string]any{"error": err.Error()})
+	}
+	mode, err := service.ParseBackfillMode(c.QueryParam("mode"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{"error": err.Error()})
+	}
+	stats, err := service.BackfillHistory(c.Request().Context(), app.Repo, steamid, mode)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]any{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]any{
+		"ok":        true,
+		"mode":      mode,
+		"games":     stats.Games,
+		"snapshots": stats.Snapshots,
 	})
 }
 
-// GET /ui/results?steamid=...
+// GET /api/summary/:steamid[?from=...&to=...]
+// Account-wide totals from the latest snapshots (or those at or before to), compared
+// with the account as of from (default 30 days ago).
+func (app *Application) APISummary(c echo.Context) error {
+	ctx := c.Request().Context()
+	steamid, status, err := app.resolveSteamID(ctx, c.Param("steamid"))
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	opts, err := compareOptions(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	summary, err := service.BuildSummary(ctx, app.Repo, steamid, opts.From, opts.To)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, summary)
+}
+
+// GET /api/steam/budget
+// Reports the shared Steam rate limiter's daily budget usage.
+func (app *Application) SteamBudget(c echo.Context) error {
+	return c.JSON(http.StatusOK, steamapi.SharedLimiter().Usage())
+}
+
+// GET /api/tracked
+// Accounts the scheduler refreshes in the background, soonest run first.
+func (app *Application) ListTracked(c echo.Context) error {
+	accounts, err := app.Repo.ListTrackedAccounts(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	out := make([]map[string]any, 0, len(accounts))
+	for _, a := range accounts {
+		out = append(out, trackedJSON(a))
+	}
+	return c.JSON(http.StatusOK, map[string]any{"tracked": out})
+}
+
+// POST /api/tracked/:steamid[?interval=6h]
+// Starts tracking an account, or changes its interval (Go duration; default from config).
+// - 400: bad steamid, unparseable interval or below the minimum
+func (app *Application) Track(c echo.Context) error {
+	ctx := c.Request().Context()
+	steamid, status, err := app.resolveSteamID(ctx, c.Param("steamid"))
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	var interval time.Duration
+	if v := c.FormValue("interval"); v != "" {
+		if interval, err = time.ParseDuration(v); err != nil || interval <= 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid interval %q (want e.g. 90m or 6h)", v)})
+		}
+	}
+	a, err := app.Scheduler.Track(ctx, steamid, interval)
+	if errors.Is(err, scheduler.ErrIntervalTooShort) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, trackedJSON(a))
+}
+
+// DELETE /api/tracked/:steamid
+// Stops background refreshes for an account (its history is kept). 404 if not tracked.
+func (app *Application) Untrack(c echo.Context) error {
+	ctx := c.Request().Context()
+	steamid, status, err := app.resolveSteamID(ctx, c.Param("steamid"))
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	removed, err := app.Scheduler.Untrack(ctx, steamid)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !removed {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "not tracked"})
+	}
+	return c.JSON(http.StatusOK, map[string]any{"ok": true, "steamid": steamid})
+}
+
+func trackedJSON(a db.TrackedAccount) map[string]any {
+	return map[string]any{
+		"steamid":          a.SteamID,
+		"interval_seconds": int64(a.Interval / time.Second),
+		"next_run_at":      a.NextRunAt,
+		"last_run_at":      a.LastRunAt, // null if it never ran
+		"last_error":       a.LastError,
+		"created_at":       a.CreatedAt,
+	}
+}
+
+// GET /api/games/:appid?steamid=...[&filter=all|locked|unlocked|recent][&spoilers=1]
+// Full achievement checklist for one game plus every snapshot (oldest first).
+func (app *Application) APIGame(c echo.Context) error {
+	d, status, err := app.gameDetail(c)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, d)
+}
+
+// GET /ui/games/:appid?steamid=...[&filter=...][&spoilers=1]
+func (app *Application) UIGame(c echo.Context) error {
+	d, status, err := app.gameDetail(c)
+	if err != nil {
+		return c.String(status, err.Error())
+	}
+	return renderUI(c, views.GameDetail(d, service.CompareOptions{ShowHidden: showSpoilers(c)}))
+}
+
+// GET /ui/results?steamid=...[&spoilers=1][&from=...&to=...]
 func (app *Application) UIResults(c echo.Context) error {
-	steamid := c.QueryParam("steamid")
-	if steamid == "" {
+	raw := c.QueryParam("steamid")
+	if raw == "" {
 		// Render the shell page if no steamid yet
 		return views.Home().Render(c.Request().Context(), c.Response())
 	}
+	steamid, status, err := app.resolveSteamID(c.Request().Context(), raw)
+	if err != nil {
+		return c.String(status, err.Error())
+	}
 
-	rows, err := service.BuildAllComparisonsForUser(c.Request().Context(), app.Repo, steamid)
+	opts, err := compareOptions(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	rows, err := service.BuildAllComparisonsForUser(c.Request().Context(), app.Repo, steamid, opts)
+	if err != nil {
+		return c.String(compareErrStatus(err), err.Error())
+	}
+	player, err := service.LoadPlayer(c.Request().Context(), app.Repo, steamid)
 	if err != nil {
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
-	return views.Results(steamid, rows).Render(c.Request().Context(), c.Response())
+	summary, err := service.BuildSummary(c.Request().Context(), app.Repo, steamid, opts.From, opts.To)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	return renderUI(c, views.Results(steamid, player, rows, opts, summary))
 }
 
 // POST /ui/refresh  (expects form field or hx-vals: steamid)
+// Starts a background refresh and returns its live status fragment. Outcomes
+// match POST /api/refresh; a 429 renders a countdown to the end of the window.
 func (app *Application) UIRefresh(c echo.Context) error {
-	steamid := c.FormValue("steamid")
-	if steamid == "" {
-		return c.String(http.StatusBadRequest, "missing steamid")
+	req := app.requestRefresh(c.Request().Context(), c.FormValue("steamid"))
+	setRetryAfter(c, req)
+	switch {
+	case req.RetryAfter > 0:
+		return render(c, req.Status, views.RefreshThrottled(req.RetryAfter))
+	case req.Err != nil:
+		return render(c, req.Status, views.RefreshError(req.Err.Error()))
+	}
+	return views.RefreshJob(req.Job, config.RefreshWorkers()).Render(c.Request().Context(), c.Response())
+}
+
+// setRetryAfter adds the Retry-After header to a throttled refresh response.
+func setRetryAfter(c echo.Context, req refreshRequest) {
+	if req.RetryAfter > 0 {
+		c.Response().Header().Set("Retry-After", strconv.Itoa(req.RetryAfter))
 	}
-	client, err := steamapi.New()
+}
+
+// GET /ui/jobs/:id  (polled by the refresh-status element while a job runs)
+func (app *Application) UIJob(c echo.Context) error {
+	j, _, ok := app.Jobs.Get(c.Param("id"))
+	if !ok {
+		return c.String(http.StatusNotFound, "refresh job not found")
+	}
+	return views.RefreshJob(j, config.RefreshWorkers()).Render(c.Request().Context(), c.Response())
+}
+
+// POST /ui/jobs/:id/cancel
+func (app *Application) UICancelJob(c echo.Context) error {
+	if !app.Jobs.Cancel(c.Param("id")) {
+		return c.String(http.StatusNotFound, "refresh job not found")
+	}
+	return app.UIJob(c)
+}
+
+// resolveSteamID turns any accepted input form (SteamID64, Steam2/3, profile URL,
+// vanity name) into a SteamID64. On failure it also returns the HTTP status to send:
+// 400 for input the user can fix, 502 when the vanity lookup itself failed.
+// Only vanity names touch Steam, through the shared app.Steam client.
+func (app *Application) resolveSteamID(ctx context.Context, raw string) (string, int, error) {
+	id, vanity, err := steamid.Parse(raw)
 	if err != nil {
-		return c.String(http.StatusInternalServerError, err.Error())
+		return "", http.StatusBadRequest, err
+	}
+	if vanity == "" {
+		return id, http.StatusOK, nil
+	}
+	var resolver steamid.VanityResolver
+	if app.Steam != nil {
+		resolver = app.Steam
+	}
+	id, err = steamid.Resolve(ctx, raw, resolver)
+	switch {
+	case err == nil:
+		return id, http.StatusOK, nil
+	case errors.Is(err, steamid.ErrInvalid), errors.Is(err, steamapi.ErrNotFound):
+		return "", http.StatusBadRequest, err
+	default:
+		return "", http.StatusBadGateway, err
+	}
+}
+
+// compareOptions reads the comparison query parameters:
+//   - spoilers=1 reveals descriptions of hidden achievements that are still locked
+//   - from/to (YYYY-MM-DD, RFC3339 or 7d/4w ago) compare the snapshots at or before each bound
+//   - from_id/to_id compare two explicit snapshots of one game
+func compareOptions(c echo.Context) (service.CompareOptions, error) {
+	opts := service.CompareOptions{ShowHidden: showSpoilers(c)}
+	now := time.Now()
+	var err error
+	if opts.From, err = service.ParseTimeBound(c.QueryParam("from"), false, now); err != nil {
+		return opts, fmt.Errorf("from: %w", err)
+	}
+	if opts.To, err = service.ParseTimeBound(c.QueryParam("to"), true, now); err != nil {
+		return opts, fmt.Errorf("to: %w", err)
+	}
+	if !opts.From.IsZero() && !opts.To.IsZero() && opts.From.After(opts.To) {
+		return opts, errors.New("from must not be after to")
+	}
+	ids := []struct {
+		name string
+		dst  *int64
+	}{{"from_id", &opts.FromID}, {"to_id", &opts.ToID}}
+	for _, p := range ids {
+		if v := c.QueryParam(p.name); v != "" {
+			if *p.dst, err = strconv.ParseInt(v, 10, 64); err != nil || *p.dst <= 0 {
+				return opts, fmt.Errorf("%s: invalid snapshot id %q", p.name, v)
+			}
+		}
 	}
-	workers := config.RefreshWorkers()
-	stats, err := service.RefreshUserConcurrent(c.Request().Context(), app.Repo, client, steamid, workers)
+	return opts, nil
+}
+
+func showSpoilers(c echo.Context) bool {
+	return c.QueryParam("spoilers") == "1"
+}
+
+// compareErrStatus maps comparison errors to HTTP statuses.
+func compareErrStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrSnapshotNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidRange):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// gameDetail loads the checklist for the :appid/steamid/filter in the request.
+// On failure it also returns the HTTP status to send.
+func (app *Application) gameDetail(c echo.Context) (service.GameDetail, int, error) {
+	ctx := c.Request().Context()
+	appid, err := strconv.ParseInt(c.Param("appid"), 10, 64)
+	if err != nil || appid <= 0 {
+		return service.GameDetail{}, http.StatusBadRequest, fmt.Errorf("invalid appid %q", c.Param("appid"))
+	}
+	if c.QueryParam("steamid") == "" {
+		return service.GameDetail{}, http.StatusBadRequest, errors.New("missing steamid")
+	}
+	steamid, status, err := app.resolveSteamID(ctx, c.QueryParam("steamid"))
 	if err != nil {
-		return c.String(http.StatusInternalServerError, err.Error())
+		return service.GameDetail{}, status, err
+	}
+	filter, err := service.ParseGameFilter(c.QueryParam("filter"))
+	if err != nil {
+		return service.GameDetail{}, http.StatusBadRequest, err
+	}
+	d, err := service.BuildGameDetail(ctx, app.Repo, steamid, appid, filter, service.CompareOptions{ShowHidden: showSpoilers(c)})
+	if errors.Is(err, db.ErrNoRows) {
+		return service.GameDetail{}, http.StatusNotFound, fmt.Errorf("app %d has no data yet; refresh first", appid)
+	}
+	if err != nil {
+		return service.GameDetail{}, http.StatusInternalServerError, err
+	}
+	return d, http.StatusOK, nil
+}
+
+// renderUI writes an htmx fragment as-is, or wrapped in the full page when the
+// URL was opened directly (bookmark, new tab, reload after hx-push-url).
+func renderUI(c echo.Context, content templ.Component) error {
+	if c.Request().Header.Get("HX-Request") == "" {
+		content = views.Layout(content)
 	}
-	return views.RefreshStatus(steamid, workers, stats).Render(c.Request().Context(), c.Response())
+	return render(c, http.StatusOK, content)
 }
@@ -0,0 +1,194 @@
+// Package scheduler refreshes tracked accounts in the background, each on its own
+// interval. The schedule lives in tracked_accounts, so it survives restarts.
+//
+// Every account runs at a fixed phase within its interval, derived from its
+// steamid, so accounts sharing an interval are spread out instead of firing
+// together. Runs pause while the Steam daily budget is below the configured
+// reserve; the throttle gate is left to RefreshFunc, which refuses a run that
+// falls inside the window.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/config"
+	"github.com/James-Wolfley/steam-achievement-tracker/db"
+	"github.com/James-Wolfley/steam-achievement-tracker/steamapi"
+)
+
+// dueBatch is how many due accounts are loaded per query.
+const dueBatch = 50
+
+// ErrIntervalTooShort is returned by Track for intervals under config.MinTrackInterval.
+var ErrIntervalTooShort = errors.New("interval too short")
+
+// RefreshFunc refreshes one account: the same work as the Refresh button,
+// including stamping the throttle gate.
+type RefreshFunc func(ctx context.Context, steamid string) error
+
+// Budget reports Steam call budget usage; *steamapi.Limiter satisfies it.
+type Budget interface {
+	Usage() steamapi.LimiterUsage
+}
+
+// Scheduler runs due refreshes one at a time. Safe for concurrent use.
+type Scheduler struct {
+	repo    db.Repo
+	budget  Budget
+	refresh RefreshFunc
+	kick    chan struct{}
+	mu      sync.Mutex // serialises RunDue
+}
+
+// Stats reports what one RunDue did.
+type Stats struct {
+	Ran      int // refreshes attempted
+	Failed   int // of those, how many returned an error
+	Deferred int // left due because the Steam budget is below the reserve
+}
+
+// New returns a scheduler that refreshes accounts with refresh.
+func New(repo db.Repo, budget Budget, refresh RefreshFunc) *Scheduler {
+	return &Scheduler{
+		repo:    repo,
+		budget:  budget,
+		refresh: refresh,
+		kick:    make(chan struct{}, 1),
+	}
+}
+
+// Track starts refreshing steamid every interval (<= 0 means the default),
+// or changes the interval of an account already tracked.
+func (s *Scheduler) Track(ctx context.Context, steamid string, interval time.Duration) (db.TrackedAccount, error) {
+	if interval <= 0 {
+		interval = config.DefaultTrackInterval()
+	}
+	if least := config.MinTrackInterval(); interval < least {
+		return db.TrackedAccount{}, fmt.Errorf("%w: minimum is %s", ErrIntervalTooShort, least)
+	}
+	next := NextRun(time.Now().UTC(), interval, steamid)
+	if err := s.repo.UpsertTrackedAccount(ctx, steamid, interval, next); err != nil {
+		return db.TrackedAccount{}, err
+	}
+	s.Kick()
+	return s.repo.GetTrackedAccount(ctx, steamid)
+}
+
+// Untrack stops refreshing steamid. It reports false if steamid wasn't tracked.
+func (s *Scheduler) Untrack(ctx context.Context, steamid string) (bool, error) {
+	return s.repo.DeleteTrackedAccount(ctx, steamid)
+}
+
+// Run handles due accounts immediately, then every tick and whenever Kick is
+// called, until ctx is done. tick <= 0 disables the scheduler.
+func (s *Scheduler) Run(ctx context.Context, tick time.Duration) {
+	if tick <= 0 {
+		return
+	}
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	for {
+		if st, err := s.RunDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("scheduler: %v", err)
+		} else if st != (Stats{}) {
+			log.Printf("scheduler: ran=%d failed=%d deferred=%d", st.Ran, st.Failed, st.Deferred)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-s.kick:
+		}
+	}
+}
+
+// Kick asks Run to look for due accounts soon. Never blocks.
+func (s *Scheduler) Kick() {
+	if s == nil {
+		return
+	}
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// RunDue refreshes every account due now, most overdue first. A failed refresh
+// is recorded on the account and doesn't stop the others.
+func (s *Scheduler) RunDue(ctx context.Context) (Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var st Stats
+	now := time.Now().UTC()
+	for {
+		due, err := s.repo.ListDueTrackedAccounts(ctx, now, dueBatch)
+		if err != nil || len(due) == 0 {
+			return st, err
+		}
+		for i, a := range due {
+			if err := ctx.Err(); err != nil {
+				return st, err
+			}
+			if s.budgetLow() {
+				// Stay due; the next tick checks again (the budget resets at UTC midnight).
+				st.Deferred += len(due) - i
+				return st, nil
+			}
+			runErr := s.refresh(ctx, a.SteamID)
+			if runErr != nil && ctx.Err() != nil {
+				return st, ctx.Err() // shutting down; leave it due
+			}
+			if errors.Is(runErr, steamapi.ErrBudgetExhausted) {
+				st.Deferred += len(due) - i
+				return st, nil
+			}
+			st.Ran++
+			msg := ""
+			if runErr != nil {
+				st.Failed++
+				msg = runErr.Error()
+			}
+			ranAt := time.Now().UTC()
+			if err := s.repo.RecordTrackedRun(ctx, a.SteamID, ranAt, NextRun(ranAt, a.Interval, a.SteamID), msg); err != nil {
+				return st, err
+			}
+		}
+	}
+}
+
+// budgetLow reports whether less than the reserved share of today's Steam budget is left.
+func (s *Scheduler) budgetLow() bool {
+	if s.budget == nil {
+		return false
+	}
+	u := s.budget.Usage()
+	if u.DailyBudget <= 0 {
+		return false // unlimited
+	}
+	left := float64(u.RemainingToday) / float64(u.DailyBudget) * 100
+	return u.RemainingToday == 0 || left < config.SchedulerBudgetReserve()
+}
+
+// NextRun is the first time after now at which steamid's phase within interval
+// comes round. The phase is a hash of steamid, so it is stable across restarts.
+func NextRun(now time.Time, interval time.Duration, steamid string) time.Time {
+	if interval <= 0 {
+		return now
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(steamid))
+	secs := max(uint64(interval/time.Second), 1)
+	phase := time.Duration(h.Sum64()%secs) * time.Second
+	t := now.Truncate(interval).Add(phase)
+	for !t.After(now) {
+		t = t.Add(interval)
+	}
+	return t.UTC()
+}
@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/db"
+	"github.com/James-Wolfley/steam-achievement-tracker/steamapi"
+)
+
+func TestNextRun(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 34, 56, 0, time.UTC)
+	tests := []struct {
+		name     string
+		interval time.Duration
+	}{
+		{"minute", time.Minute},
+		{"hour", time.Hour},
+		{"six hours", 6 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"week", 7 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("stable per steamid", func(t *testing.T) {
+				const steamid = "76561190000000001"
+				next := NextRun(now, tt.interval, steamid)
+				if !next.After(now) || next.Sub(now) > tt.interval {
+					t.Fatalf("NextRun = %s, want within (now, now+%s]", next, tt.interval)
+				}
+				// Asking again later in the same cycle (a restart) lands on the same run.
+				for _, later := range []time.Time{now, now.Add(next.Sub(now) / 2), next.Add(-time.Second)} {
+					if got := NextRun(later, tt.interval, steamid); !got.Equal(next) {
+						t.Errorf("NextRun(%s) = %s, want %s", later, got, next)
+					}
+				}
+				// The run after that is one interval on.
+				if got := NextRun(next, tt.interval, steamid); !got.Equal(next.Add(tt.interval)) {
+					t.Errorf("NextRun(next) = %s, want %s", got, next.Add(tt.interval))
+				}
+			})
+
+			t.Run("spread across accounts", func(t *testing.T) {
+				const accounts, buckets = 400, 4
+				var counts [buckets]int
+				for i := 0; i < accounts; i++ {
+					next := NextRun(now, tt.interval, fmt.Sprintf("7656119%010d", i))
+					phase := next.Sub(now.Truncate(tt.interval)) % tt.interval
+					counts[int(phase*buckets/tt.interval)]++
+				}
+				for b, n := range counts {
+					if n < accounts/buckets/2 {
+						t.Errorf("quarter %d of the interval has %d of %d accounts: %v", b, n, accounts, counts)
+					}
+				}
+			})
+		})
+	}
+
+	if got := NextRun(now, 0, "x"); !got.Equal(now) {
+		t.Errorf("NextRun with no interval = %s, want now", got)
+	}
+}
+
+// fakeBudget reports a fixed usage.
+type fakeBudget steamapi.LimiterUsage
+
+func (b fakeBudget) Usage() steamapi.LimiterUsage { return steamapi.LimiterUsage(b) }
+
+func TestRunDueBudgetReserve(t *testing.T) {
+	t.Setenv("SCHEDULER_BUDGET_RESERVE_PCT", "20")
+	const accounts = 3
+	tests := []struct {
+		name     string
+		budget   Budget
+		ran      int
+		deferred int
+	}{
+		{"no limiter", nil, accounts, 0},
+		{"unlimited", fakeBudget{DailyBudget: 0}, accounts, 0},
+		{"above reserve", fakeBudget{DailyBudget: 1000, RemainingToday: 500}, accounts, 0},
+		{"at reserve", fakeBudget{DailyBudget: 1000, RemainingToday: 200}, accounts, 0},
+		{"below reserve", fakeBudget{DailyBudget: 1000, RemainingToday: 199}, 0, accounts},
+		{"spent", fakeBudget{DailyBudget: 1000}, 0, accounts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := openTestDB(t)
+			due := time.Now().UTC().Add(-time.Minute)
+			for i := 0; i < accounts; i++ {
+				if err := repo.UpsertTrackedAccount(ctx, fmt.Sprintf("7656119%010d", i), time.Hour, due); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var refreshed []string
+			s := New(repo, tt.budget, func(ctx context.Context, steamid string) error {
+				refreshed = append(refreshed, steamid)
+				return nil
+			})
+
+			st, err := s.RunDue(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st.Ran != tt.ran || st.Deferred != tt.deferred || len(refreshed) != tt.ran {
+				t.Errorf("stats = %+v after %d refreshes, want %d ran and %d deferred", st, len(refreshed), tt.ran, tt.deferred)
+			}
+			// Deferred accounts stay due for the next tick.
+			left, err := repo.ListDueTrackedAccounts(ctx, time.Now().UTC(), accounts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(left) != tt.deferred {
+				t.Errorf("%d accounts still due, want %d", len(left), tt.deferred)
+			}
+		})
+	}
+}
+
+func openTestDB(t *testing.T) db.Repo {
+	t.Helper()
+	sqlDB, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.ApplyMigrations(context.Background(), sqlDB, db.Migrations()); err != nil {
+		t.Fatal(err)
+	}
+	return db.NewRepo(sqlDB)
+}
@@ -0,0 +1,28 @@
+// htmx ignores 4xx/5xx bodies by default; swap them in so validation,
+// throttling and Steam lookup messages reach the user. The refresh status
+// always shows its error fragment, whatever the status.
+document.addEventListener("htmx:beforeSwap", (evt) => {
+  const status = evt.detail.xhr.status;
+  const refreshStatus = evt.detail.target && evt.detail.target.id === "refresh-status";
+  if (status === 400 || status === 429 || status === 502 || status === 503 ||
+      (status >= 400 && refreshStatus)) {
+    evt.detail.shouldSwap = true;
+    evt.detail.isError = false;
+  }
+});
+
+// Throttle countdowns: an element with data-countdown="N" shows the seconds
+// left in its [data-countdown-left] child, then its data-countdown-done text.
+setInterval(() => {
+  document.querySelectorAll("[data-countdown]").forEach((el) => {
+    const left = Number(el.dataset.countdown) - 1;
+    if (left > 0) {
+      el.dataset.countdown = left;
+      const out = el.querySelector("[data-countdown-left]");
+      if (out) out.textContent = `${left}s`;
+      return;
+    }
+    el.removeAttribute("data-countdown");
+    el.textContent = el.dataset.countdownDone || "";
+  });
+}, 1000);
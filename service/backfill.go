@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/db"
+)
+
+// BackfillMode controls how synthetic history is laid out.
+type BackfillMode string
+
+const (
+	BackfillPerUnlock BackfillMode = "unlock" // one snapshot per distinct unlock time
+	BackfillDaily     BackfillMode = "daily"  // one snapshot per UTC day with unlocks
+)
+
+// ParseBackfillMode accepts "unlock" or "daily" ("" defaults to daily).
+func ParseBackfillMode(s string) (BackfillMode, error) {
+	switch BackfillMode(s) {
+	case "":
+		return BackfillDaily, nil
+	case BackfillPerUnlock, BackfillDaily:
+		return BackfillMode(s), nil
+	default:
+		return "", fmt.Errorf("unknown backfill mode %q (want unlock or daily)", s)
+	}
+}
+
+// BackfillStats reports what a backfill run produced.
+type BackfillStats struct {
+	Games     int // games that received at least one synthetic snapshot
+	Snapshots int // synthetic snapshots written
+}
+
+// BackfillHistory rebuilds synthetic history for every game the user has snapshots for.
+// Re-running replaces earlier synthetic snapshots, so it is safe to call repeatedly.
+func BackfillHistory(ctx context.Context, repo db.Repo, steamid string, mode BackfillMode) (BackfillStats, error) {
+	appids, err := repo.ListAppIDsWithSnapshots(ctx, steamid)
+	if err != nil {
+		return BackfillStats{}, err
+	}
+	var stats BackfillStats
+	for _, appid := range appids {
+		n, err := BackfillGame(ctx, repo, steamid, appid, mode)
+		if err != nil {
+			return stats, fmt.Errorf("backfill app %d: %w", appid, err)
+		}
+		if n > 0 {
+			stats.Games++
+			stats.Snapshots += n
+		}
+	}
+	return stats, nil
+}
+
+// BackfillGame derives the completion curve before the first observed snapshot
+// from stored unlock times and writes it as synthetic snapshots.
+// The current catalog is assumed for every synthetic point.
+func BackfillGame(ctx context.Context, repo db.Repo, steamid string, appid int64, mode BackfillMode) (int, error) {
+	states, err := repo.GetPlayerAchievementStates(ctx, steamid, appid)
+	if err != nil {
+		return 0, err
+	}
+	if len(states) == 0 {
+		return 0, nil
+	}
+
+	// Synthetic history stops where real observations begin.
+	cutoff := time.Now().UTC()
+	var firstStateHash string
+	first, err := repo.GetOldestObservedSnapshot(ctx, steamid, appid)
+	switch {
+	case err == nil:
+		cutoff = first.TakenAt
+		firstStateHash = first.StateHash
+	case !errors.Is(err, db.ErrNoRows):
+		return 0, err
+	}
+
+	apinames := make([]string, 0, len(states))
+	var unlocks []db.PlayerAchievementState
+	for _, st := range states {
+		apinames = append(apinames, st.APIName)
+		if st.Achieved && st.UnlockTime != nil && st.UnlockTime.Before(cutoff) {
+			unlocks = append(unlocks, st)
+		}
+	}
+	sort.Slice(unlocks, func(i, j int) bool { return unlocks[i].UnlockTime.Before(*unlocks[j].UnlockTime) })
+	catHash := db.CatalogHash(appid, apinames)
+
+	achieved := make(map[string]bool, len(apinames))
+	for _, api := range apinames {
+		achieved[api] = false
+	}
+
+	var ins []db.SnapshotInsert
+	done := 0
+	for i := 0; i < len(unlocks); {
+		// Group unlocks that land in the same bucket.
+		key := backfillBucket(*unlocks[i].UnlockTime, mode)
+		at := *unlocks[i].UnlockTime
+		for ; i < len(unlocks) && backfillBucket(*unlocks[i].UnlockTime, mode).Equal(key); i++ {
+			achieved[unlocks[i].APIName] = true
+			done++
+			at = *unlocks[i].UnlockTime
+		}
+		if mode == BackfillDaily {
+			// Stamp at end of day, but never at or after the first real snapshot.
+			at = key.Add(24*time.Hour - time.Second)
+			if !at.Before(cutoff) {
+				at = cutoff.Add(-time.Second)
+			}
+		}
+
+		items := db.BuildSnapshotAchievements(achieved)
+		stateHash := db.StateHash(appid, items)
+		if stateHash == firstStateHash {
+			// Identical to the first observation; that snapshot already covers it.
+			continue
+		}
+		ins = append(ins, db.SnapshotInsert{
+			SteamID:        steamid,
+			AppID:          appid,
+			TotalDone:      done,
+			TotalAvailable: len(apinames),
+			CatalogHash:    catHash,
+			StateHash:      stateHash,
+			Achievements:   items,
+			TakenAt:        at,
+			Synthetic:      true,
+		})
+	}
+
+	return repo.ReplaceSyntheticSnapshots(ctx, steamid, appid, ins)
+}
+
+// backfillBucket returns the grouping key for an unlock time.
+func backfillBucket(t time.Time, mode BackfillMode) time.Time {
+	if mode == BackfillDaily {
+		y, m, d := t.UTC().Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	return t.UTC()
+}
@@ -2,54 +2,218 @@ package service
 
 import (
 	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/James-Wolfley/steam-achievement-tracker/compare"
 	"github.com/James-Wolfley/steam-achievement-tracker/db"
+	"github.com/James-Wolfley/steam-achievement-tracker/icons"
 )
 
-// BuildComparisonForGame fetches the last two snapshots + per-snapshot achievements
-// for (steamid, appid), computes diffs, and returns a ready-to-render row.
-// ok=false means there is no "current" snapshot yet.
-func BuildComparisonForGame(ctx context.Context, repo db.Repo, steamid string, appid int64) (row compare.Row, ok bool, err error) {
-	// 1) get last two snapshots
-	snaps, err := repo.GetLatestSnapshots(ctx, steamid, appid, 2)
+// CompareOptions tweaks how comparison rows are built. Zero value is the safe default
+// (the latest two snapshots of every game).
+type CompareOptions struct {
+	ShowHidden bool // reveal descriptions of hidden achievements the player hasn't unlocked
+
+	// From/To select snapshots by time: the newest one at or before each bound.
+	// Zero To means the latest snapshot; zero From means the one just before it.
+	From, To time.Time
+	// FromID/ToID select explicit snapshots instead (both must belong to one game).
+	FromID, ToID int64
+}
+
+// ErrSnapshotNotFound is returned when FromID/ToID don't name a snapshot of this player.
+var ErrSnapshotNotFound = errors.New("snapshot not found for this player")
+
+// ErrInvalidRange is returned when the selected snapshots are out of order or span games.
+var ErrInvalidRange = errors.New("invalid snapshot range")
+
+func (o CompareOptions) hasRange() bool {
+	return !o.From.IsZero() || !o.To.IsZero() || o.FromID != 0 || o.ToID != 0
+}
+
+// BuildComparisonForGame picks two snapshots for (steamid, appid) — the latest pair by
+// default, or whatever opts selects — diffs them and returns a ready-to-render row.
+// ok=false means there is no "current" snapshot (none yet, or none at or before opts.To).
+func BuildComparisonForGame(ctx context.Context, repo db.Repo, steamid string, appid int64, opts CompareOptions) (row compare.Row, ok bool, err error) {
+	// 1) pick the two snapshots + their per-snapshot achievements, and diff them
+	prevSnap, currSnap, prevAch, currAch, err := selectSnapshots(ctx, repo, steamid, appid, opts)
 	if err != nil {
 		return compare.Row{}, false, err
 	}
-	if len(snaps) == 0 {
+	if currSnap == nil {
 		return compare.Row{}, false, nil
 	}
+	diff := db.DiffSnapshotAchievements(prevAch, currAch)
 
-	var prevSnap *db.Snapshot
-	currSnap := snaps[0]
-	if len(snaps) > 1 {
-		prevSnap = &snaps[1]
+	// 2) real unlock times from the player's current state + catalog (names, rarity)
+	states, err := repo.GetPlayerAchievementStates(ctx, steamid, appid)
+	if err != nil {
+		return compare.Row{}, false, err
 	}
-
-	// 2) get per-snapshot achievements (prev,curr) and diff them
-	prevAch, currAch, err := repo.GetLatestSnapshotAchievementsPair(ctx, steamid, appid)
+	defs, err := repo.GetAchievementDefs(ctx, appid)
 	if err != nil {
 		return compare.Row{}, false, err
 	}
-	diff := db.DiffSnapshotAchievements(prevAch, currAch)
+	game, err := repo.GetGame(ctx, appid)
+	if err != nil && !errors.Is(err, db.ErrNoRows) {
+		return compare.Row{}, false, err
+	}
+	if err := localizeIcons(ctx, repo, defs, &game); err != nil {
+		return compare.Row{}, false, err
+	}
 
 	// 3) assemble the row
-	row = compare.BuildRow(prevSnap, currSnap, diff)
-	return row, true, nil
+	return assembleRow(prevSnap, *currSnap, diff, currAch, defs, states, game, opts), true, nil
+}
+
+// assembleRow builds the row once snapshots and per-game data are loaded.
+func assembleRow(prev *db.Snapshot, curr db.Snapshot, diff db.AchievementDiff, currAch []db.SnapshotAchievement,
+	defs []db.AchievementDef, states []db.PlayerAchievementState, game db.Game, opts CompareOptions) compare.Row {
+	return compare.BuildRow(prev, curr, diff, compare.Extras{
+		Catalog:     catalogByAPIName(defs),
+		UnlockTimes: unlockTimes(states),
+		Current:     currAch,
+		ShowHidden:  opts.ShowHidden,
+		GameName:    game.Name,
+		GameIcon:    game.IconURL,
+	})
+}
+
+// selectSnapshots resolves opts to a (prev, curr) pair and loads their achievements.
+// curr is nil when nothing matches; prev is nil when curr is the first snapshot in range.
+func selectSnapshots(ctx context.Context, repo db.Repo, steamid string, appid int64, opts CompareOptions) (prev, curr *db.Snapshot, prevAch, currAch []db.SnapshotAchievement, err error) {
+	if !opts.hasRange() {
+		snaps, err := repo.GetLatestSnapshots(ctx, steamid, appid, 2)
+		if err != nil || len(snaps) == 0 {
+			return nil, nil, nil, nil, err
+		}
+		curr = &snaps[0]
+		if len(snaps) > 1 {
+			prev = &snaps[1]
+		}
+		prevAch, currAch, err = repo.GetLatestSnapshotAchievementsPair(ctx, steamid, appid)
+		return prev, curr, prevAch, currAch, err
+	}
+
+	// Current end of the range
+	switch {
+	case opts.ToID != 0:
+		curr, err = snapshotByID(ctx, repo, steamid, appid, opts.ToID)
+	case !opts.To.IsZero():
+		curr, err = optionalSnapshot(repo.GetSnapshotAtOrBefore(ctx, steamid, appid, opts.To))
+	default:
+		var snaps []db.Snapshot
+		snaps, err = repo.GetLatestSnapshots(ctx, steamid, appid, 1)
+		if len(snaps) > 0 {
+			curr = &snaps[0]
+		}
+	}
+	if err != nil || curr == nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// Previous end of the range
+	switch {
+	case opts.FromID != 0:
+		prev, err = snapshotByID(ctx, repo, steamid, appid, opts.FromID)
+	case !opts.From.IsZero():
+		prev, err = optionalSnapshot(repo.GetSnapshotAtOrBefore(ctx, steamid, appid, opts.From))
+	default:
+		prev, err = optionalSnapshot(repo.GetPreviousSnapshot(ctx, *curr))
+	}
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if prev != nil && prev.TakenAt.After(curr.TakenAt) {
+		return nil, nil, nil, nil, fmt.Errorf("%w: snapshot %d is newer than snapshot %d", ErrInvalidRange, prev.ID, curr.ID)
+	}
+
+	if currAch, err = repo.GetSnapshotAchievements(ctx, curr.ID); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if prev != nil {
+		if prevAch, err = repo.GetSnapshotAchievements(ctx, prev.ID); err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+	return prev, curr, prevAch, currAch, nil
 }
 
-// BuildAllComparisonsForUser lists all appids with snapshots and builds rows.
+// snapshotByID loads an explicitly requested snapshot and checks it belongs to (steamid, appid).
+func snapshotByID(ctx context.Context, repo db.Repo, steamid string, appid int64, id int64) (*db.Snapshot, error) {
+	s, err := repo.GetSnapshotByID(ctx, id)
+	if errors.Is(err, db.ErrNoRows) || (err == nil && s.SteamID != steamid) {
+		return nil, fmt.Errorf("%w: %d", ErrSnapshotNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.AppID != appid {
+		return nil, fmt.Errorf("%w: snapshot %d is for app %d, not %d", ErrInvalidRange, id, s.AppID, appid)
+	}
+	return &s, nil
+}
+
+// optionalSnapshot turns ErrNoRows into a nil snapshot.
+func optionalSnapshot(s db.Snapshot, err error) (*db.Snapshot, error) {
+	if errors.Is(err, db.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ParseTimeBound parses a from/to query value: "" (unbounded), RFC3339, a UTC date
+// (YYYY-MM-DD; end of that day when endOfDay), or a relative "7d" / "4w" (ago, from now).
+func ParseTimeBound(v string, endOfDay bool, now time.Time) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		if endOfDay {
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		return t, nil
+	}
+	if n := len(v); n >= 2 {
+		if k, err := strconv.Atoi(v[:n-1]); err == nil && k >= 0 {
+			switch v[n-1] {
+			case 'd':
+				return now.UTC().AddDate(0, 0, -k), nil
+			case 'w':
+				return now.UTC().AddDate(0, 0, -7*k), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (want YYYY-MM-DD, RFC3339, or e.g. 7d / 4w)", v)
+}
+
+// BuildAllComparisonsForUser builds a row per game with snapshots.
 // If there are no snapshots for the user yet, returns an empty slice.
-func BuildAllComparisonsForUser(ctx context.Context, repo db.Repo, steamid string) ([]compare.Row, error) {
-	appids, err := repo.ListAppIDsWithSnapshots(ctx, steamid)
+// With explicit snapshot IDs only that snapshot's game is compared.
+func BuildAllComparisonsForUser(ctx context.Context, repo db.Repo, steamid string, opts CompareOptions) ([]compare.Row, error) {
+	if opts.FromID == 0 && opts.ToID == 0 {
+		return buildSetComparisons(ctx, repo, steamid, opts)
+	}
+	appids, err := comparisonAppIDs(ctx, repo, steamid, opts)
 	if err != nil {
 		return nil, err
 	}
 	rows := make([]compare.Row, 0, len(appids))
 	for _, appid := range appids {
-		r, ok, err := BuildComparisonForGame(ctx, repo, steamid, appid)
+		r, ok, err := BuildComparisonForGame(ctx, repo, steamid, appid, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -60,29 +224,184 @@ func BuildAllComparisonsForUser(ctx context.Context, repo db.Repo, steamid strin
 	return rows, nil
 }
 
-// WriteCSV writes a CSV export (header + rows) to w.
-func WriteCSV(w io.Writer, rows []compare.Row) error {
-	header := compare.CSVHeader()
-	if _, err := fmt.Fprintln(w, joinCSV(header)); err != nil {
-		return err
+// buildSetComparisons is the every-game view (the latest pair, or the pair opts.From/To
+// select), loaded with a fixed number of set-based queries instead of several per game.
+func buildSetComparisons(ctx context.Context, repo db.Repo, steamid string, opts CompareOptions) ([]compare.Row, error) {
+	var pairs []db.SnapshotPair
+	var achBySnap map[int64][]db.SnapshotAchievement
+	var err error
+	if !opts.hasRange() {
+		pairs, err = repo.GetLatestSnapshotPairs(ctx, steamid)
+		if err == nil && len(pairs) > 0 {
+			achBySnap, err = repo.GetLatestSnapshotPairAchievements(ctx, steamid)
+		}
+	} else {
+		pairs, err = snapshotPairsInRange(ctx, repo, steamid, opts.From, opts.To)
+		if err == nil && len(pairs) > 0 {
+			achBySnap, err = repo.GetSnapshotsInRangeAchievements(ctx, steamid, opts.From, opts.To)
+		}
 	}
-	for _, r := range rows {
-		if _, err := fmt.Fprintln(w, joinCSV(r.ToCSV())); err != nil {
-			return err
+	if err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return []compare.Row{}, nil
+	}
+	defsByApp, err := repo.GetAchievementDefsForUser(ctx, steamid)
+	if err != nil {
+		return nil, err
+	}
+	statesByApp, err := repo.GetPlayerAchievementStatesForUser(ctx, steamid)
+	if err != nil {
+		return nil, err
+	}
+	games, err := repo.GetGamesForUser(ctx, steamid)
+	if err != nil {
+		return nil, err
+	}
+
+	// One icon lookup for the whole page
+	var refs []*string
+	for _, defs := range defsByApp {
+		for i := range defs {
+			refs = append(refs, &defs[i].Icon, &defs[i].IconGray)
 		}
 	}
-	return nil
+	gameList := make([]db.Game, 0, len(games))
+	for _, g := range games {
+		gameList = append(gameList, g)
+	}
+	for i := range gameList {
+		refs = append(refs, &gameList[i].IconURL)
+	}
+	if err := localizeURLs(ctx, repo, refs); err != nil {
+		return nil, err
+	}
+	for _, g := range gameList {
+		games[g.AppID] = g
+	}
+
+	rows := make([]compare.Row, 0, len(pairs))
+	for _, p := range pairs {
+		currAch := achBySnap[p.Curr.ID]
+		var prevAch []db.SnapshotAchievement
+		if p.Prev != nil {
+			prevAch = achBySnap[p.Prev.ID]
+		}
+		diff := db.DiffSnapshotAchievements(prevAch, currAch)
+		appid := p.Curr.AppID
+		rows = append(rows, assembleRow(p.Prev, p.Curr, diff, currAch, defsByApp[appid], statesByApp[appid], games[appid], opts))
+	}
+	return rows, nil
+}
+
+// snapshotPairsInRange pairs each game's snapshot at or before to with its snapshot at
+// or before from (or the one before it when from is zero).
+func snapshotPairsInRange(ctx context.Context, repo db.Repo, steamid string, from, to time.Time) ([]db.SnapshotPair, error) {
+	prev, curr, err := repo.GetSnapshotsInRange(ctx, steamid, from, to)
+	if err != nil {
+		return nil, err
+	}
+	prevByApp := make(map[int64]db.Snapshot, len(prev))
+	for _, p := range prev {
+		prevByApp[p.AppID] = p
+	}
+	pairs := make([]db.SnapshotPair, 0, len(curr))
+	for _, c := range curr {
+		pair := db.SnapshotPair{Curr: c}
+		if p, ok := prevByApp[c.AppID]; ok {
+			if p.TakenAt.After(c.TakenAt) {
+				return nil, fmt.Errorf("%w: snapshot %d is newer than snapshot %d", ErrInvalidRange, p.ID, c.ID)
+			}
+			pair.Prev = &p
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, nil
 }
 
-// joinCSV is a minimal CSV joiner; values should already be safe/plain.
-// (For robust quoting, you can swap to encoding/csv later.)
-func joinCSV(cols []string) string {
-	if len(cols) == 0 {
-		return ""
+// comparisonAppIDs is every game with snapshots, or just the game the explicit IDs point at.
+func comparisonAppIDs(ctx context.Context, repo db.Repo, steamid string, opts CompareOptions) ([]int64, error) {
+	id := opts.ToID
+	if id == 0 {
+		id = opts.FromID
 	}
-	out := cols[0]
-	for i := 1; i < len(cols); i++ {
-		out += "," + cols[i]
+	if id == 0 {
+		return repo.ListAppIDsWithSnapshots(ctx, steamid)
+	}
+	s, err := repo.GetSnapshotByID(ctx, id)
+	if errors.Is(err, db.ErrNoRows) || (err == nil && s.SteamID != steamid) {
+		return nil, fmt.Errorf("%w: %d", ErrSnapshotNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []int64{s.AppID}, nil
+}
+
+// catalogByAPIName indexes catalog entries by apiname.
+func catalogByAPIName(defs []db.AchievementDef) map[string]db.AchievementDef {
+	out := make(map[string]db.AchievementDef, len(defs))
+	for _, d := range defs {
+		out[d.APIName] = d
+	}
+	return out
+}
+
+// localizeIcons points icon URLs at the local cache so pages never hotlink Steam.
+// Icons not downloaded yet are blanked rather than served remotely.
+func localizeIcons(ctx context.Context, repo db.Repo, defs []db.AchievementDef, game *db.Game) error {
+	refs := make([]*string, 0, 2*len(defs)+1)
+	for i := range defs {
+		refs = append(refs, &defs[i].Icon, &defs[i].IconGray)
+	}
+	refs = append(refs, &game.IconURL)
+	return localizeURLs(ctx, repo, refs)
+}
+
+// localizeURLs rewrites each referenced URL to its local copy ("" if not cached yet).
+func localizeURLs(ctx context.Context, repo db.Repo, refs []*string) error {
+	seen := make(map[string]bool, len(refs))
+	urls := make([]string, 0, len(refs))
+	for _, r := range refs {
+		if *r != "" && !seen[*r] {
+			seen[*r] = true
+			urls = append(urls, *r)
+		}
+	}
+	local, err := icons.Localize(ctx, repo, urls)
+	if err != nil {
+		return err
+	}
+	for _, r := range refs {
+		*r = local[*r]
+	}
+	return nil
+}
+
+// unlockTimes indexes known unlock times by apiname.
+func unlockTimes(states []db.PlayerAchievementState) map[string]time.Time {
+	out := make(map[string]time.Time, len(states))
+	for _, st := range states {
+		if st.Achieved && st.UnlockTime != nil {
+			out[st.APIName] = *st.UnlockTime
+		}
 	}
 	return out
 }
+
+// WriteCSV writes a CSV export (header + rows) to w.
+// Display names can contain commas and quotes, so fields are quoted by encoding/csv.
+func WriteCSV(w io.Writer, rows []compare.Row) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(compare.CSVHeader()); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := cw.Write(r.ToCSV()); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
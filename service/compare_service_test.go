@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/compare"
+	"github.com/James-Wolfley/steam-achievement-tracker/db"
+)
+
+const testSteamID = "76561190000000000"
+
+// openTestDB returns a migrated in-memory database.
+func openTestDB(t testing.TB) db.Repo {
+	t.Helper()
+	sqlDB, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.ApplyMigrations(context.Background(), sqlDB, db.Migrations()); err != nil {
+		t.Fatal(err)
+	}
+	return db.NewRepo(sqlDB)
+}
+
+// seed writes games, catalogs, current states and a chain of daily snapshots ending
+// at base, where each snapshot unlocks a few more achievements than the one before it.
+func seed(t testing.TB, repo db.Repo, base time.Time, games, achievements, snapshots int) {
+	t.Helper()
+	ctx := context.Background()
+	if err := repo.UpsertPlayer(ctx, db.Player{SteamID: testSteamID, PersonaName: "bench"}); err != nil {
+		t.Fatal(err)
+	}
+	for g := 0; g < games; g++ {
+		appid := int64(100000 + g)
+		if err := repo.UpsertGame(ctx, db.Game{AppID: appid, Name: fmt.Sprintf("Game %d", g)}); err != nil {
+			t.Fatal(err)
+		}
+		defs := make([]db.AchievementDef, 0, achievements)
+		apilist := make([]string, 0, achievements)
+		for a := 0; a < achievements; a++ {
+			name := fmt.Sprintf("ACH_%03d", a)
+			apilist = append(apilist, name)
+			defs = append(defs, db.AchievementDef{AppID: appid, APIName: name, Name: fmt.Sprintf("Achievement %d", a)})
+		}
+		if err := repo.UpsertAchievementDefs(ctx, defs); err != nil {
+			t.Fatal(err)
+		}
+
+		catHash := db.CatalogHash(appid, apilist)
+		state := make(map[string]bool, achievements)
+		// Stagger games so they don't all have a snapshot on every day.
+		first := g % snapshots
+		for s := first; s < snapshots; s++ {
+			done := 0
+			for a, name := range apilist {
+				state[name] = a < (s+1)*achievements/(snapshots+1)
+				if state[name] {
+					done++
+				}
+			}
+			items := db.BuildSnapshotAchievements(state)
+			if _, err := repo.InsertSnapshot(ctx, db.SnapshotInsert{
+				SteamID:        testSteamID,
+				AppID:          appid,
+				TotalDone:      done,
+				TotalAvailable: len(apilist),
+				CatalogHash:    catHash,
+				StateHash:      db.StateHash(appid, items),
+				Achievements:   items,
+				TakenAt:        base.Add(-time.Duration(snapshots-1-s) * 24 * time.Hour),
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		rows := make([]db.PlayerAchievementState, 0, achievements)
+		for _, name := range apilist {
+			rows = append(rows, db.PlayerAchievementState{SteamID: testSteamID, AppID: appid, APIName: name, Achieved: state[name]})
+		}
+		if err := repo.UpsertPlayerAchievementState(ctx, rows); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAllComparisonsMatchPerGame(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestDB(t)
+	base := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	seed(t, repo, base, 7, 12, 4)
+
+	day := 24 * time.Hour
+	tests := []struct {
+		name string
+		opts CompareOptions
+	}{
+		{"latest", CompareOptions{}},
+		{"to", CompareOptions{To: base.Add(-day)}},
+		{"from", CompareOptions{From: base.Add(-2 * day)}},
+		{"from and to", CompareOptions{From: base.Add(-3 * day), To: base.Add(-day - time.Hour)}},
+		{"before everything", CompareOptions{To: base.Add(-10 * day)}},
+		{"from before everything", CompareOptions{From: base.Add(-10 * day)}},
+	}
+	appids, err := repo.ListAppIDsWithSnapshots(ctx, testSteamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildAllComparisonsForUser(ctx, repo, testSteamID, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []compare.Row
+			for _, appid := range appids {
+				r, ok, err := BuildComparisonForGame(ctx, repo, testSteamID, appid, tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ok {
+					want = append(want, r)
+				}
+			}
+			if len(got) != len(want) {
+				t.Fatalf("%d rows, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if !reflect.DeepEqual(got[i], want[i]) {
+					t.Errorf("app %d:\n got %+v\nwant %+v", got[i].AppID, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBuildSummary(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestDB(t)
+	base := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	seed(t, repo, base, 5, 10, 3)
+
+	day := 24 * time.Hour
+	tests := []struct {
+		name         string
+		since, until time.Time
+	}{
+		{"latest", base.Add(-day), time.Time{}},
+		{"until", base.Add(-2 * day), base.Add(-day)},
+		{"since before everything", base.Add(-10 * day), time.Time{}},
+		{"since after until", base, base.Add(-day)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildSummary(ctx, repo, testSteamID, tt.since, tt.until)
+			if err != nil {
+				t.Fatal(err)
+			}
+			curr, prev := snapshotsPerGame(t, repo, tt.until), snapshotsPerGame(t, repo, tt.since)
+			if want := summarize(curr); got.Current != want {
+				t.Errorf("Current = %+v, want %+v", got.Current, want)
+			}
+			if want := summarize(prev); got.Previous != want {
+				t.Errorf("Previous = %+v, want %+v", got.Previous, want)
+			}
+		})
+	}
+}
+
+// snapshotsPerGame is the newest snapshot of each game at or before t (latest if zero),
+// looked up one game at a time.
+func snapshotsPerGame(t *testing.T, repo db.Repo, at time.Time) []db.Snapshot {
+	t.Helper()
+	ctx := context.Background()
+	appids, err := repo.ListAppIDsWithSnapshots(ctx, testSteamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []db.Snapshot
+	for _, appid := range appids {
+		if at.IsZero() {
+			snaps, err := repo.GetLatestSnapshots(ctx, testSteamID, appid, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, snaps...)
+			continue
+		}
+		s, err := optionalSnapshot(repo.GetSnapshotAtOrBefore(ctx, testSteamID, appid, at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != nil {
+			out = append(out, *s)
+		}
+	}
+	return out
+}
+
+func BenchmarkBuildAllComparisons(b *testing.B) {
+	repo := openTestDB(b)
+	base := time.Now().UTC()
+	seed(b, repo, base, 500, 30, 3)
+	ctx := context.Background()
+
+	for _, bb := range []struct {
+		name string
+		opts CompareOptions
+	}{
+		{"latest", CompareOptions{}},
+		{"range", CompareOptions{From: base.Add(-36 * time.Hour), To: base.Add(-time.Hour)}},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := BuildAllComparisonsForUser(ctx, repo, testSteamID, bb.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	// The per-game path the set-based queries replaced, for comparison.
+	b.Run("per_game", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			appids, err := repo.ListAppIDsWithSnapshots(ctx, testSteamID)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, appid := range appids {
+				if _, _, err := BuildComparisonForGame(ctx, repo, testSteamID, appid, CompareOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkBuildSummary(b *testing.B) {
+	repo := openTestDB(b)
+	seed(b, repo, time.Now().UTC(), 500, 30, 3)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := BuildSummary(ctx, repo, testSteamID, time.Time{}, time.Time{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/compare"
+	"github.com/James-Wolfley/steam-achievement-tracker/db"
+)
+
+// RecentWindow is how far back an unlock counts as "recently earned".
+const RecentWindow = 30 * 24 * time.Hour
+
+// GameFilter narrows the achievement checklist on the game detail page.
+type GameFilter string
+
+const (
+	FilterAll      GameFilter = "all"
+	FilterLocked   GameFilter = "locked"
+	FilterUnlocked GameFilter = "unlocked"
+	FilterRecent   GameFilter = "recent" // unlocked within RecentWindow, newest first
+)
+
+// ParseGameFilter accepts all, locked, unlocked or recent ("" defaults to all).
+func ParseGameFilter(s string) (GameFilter, error) {
+	switch GameFilter(s) {
+	case "":
+		return FilterAll, nil
+	case FilterAll, FilterLocked, FilterUnlocked, FilterRecent:
+		return GameFilter(s), nil
+	default:
+		return "", fmt.Errorf("unknown filter %q (want all, locked, unlocked or recent)", s)
+	}
+}
+
+// GameDetail is the full checklist for one game plus its snapshot history.
+type GameDetail struct {
+	SteamID  string
+	AppID    int64
+	GameName string
+	GameIcon string
+	Filter   GameFilter
+
+	// Totals over the whole catalog (not just the filtered list)
+	Done, Total int
+	Pct         float64
+
+	Achievements []compare.Achievement // filtered
+	History      []db.Snapshot         // every snapshot, oldest first
+}
+
+// BuildGameDetail lists every catalog achievement for appid with the player's state.
+// Returns db.ErrNoRows if the game isn't known.
+func BuildGameDetail(ctx context.Context, repo db.Repo, steamid string, appid int64, filter GameFilter, opts CompareOptions) (GameDetail, error) {
+	game, err := repo.GetGame(ctx, appid)
+	if err != nil {
+		return GameDetail{}, err
+	}
+	defs, err := repo.GetAchievementDefs(ctx, appid)
+	if err != nil {
+		return GameDetail{}, err
+	}
+	if err := localizeIcons(ctx, repo, defs, &game); err != nil {
+		return GameDetail{}, err
+	}
+	states, err := repo.GetPlayerAchievementStates(ctx, steamid, appid)
+	if err != nil {
+		return GameDetail{}, err
+	}
+	history, err := repo.ListSnapshots(ctx, steamid, appid)
+	if err != nil {
+		return GameDetail{}, err
+	}
+
+	// Current state: the player's stored state, or the latest snapshot if none was stored.
+	achieved := make(map[string]bool, len(defs))
+	for _, st := range states {
+		achieved[st.APIName] = st.Achieved
+	}
+	if len(states) == 0 && len(history) > 0 {
+		items, err := repo.GetSnapshotAchievements(ctx, history[len(history)-1].ID)
+		if err != nil {
+			return GameDetail{}, err
+		}
+		for _, sa := range items {
+			achieved[sa.APIName] = sa.Achieved
+		}
+	}
+
+	ex := compare.Extras{
+		Catalog:     catalogByAPIName(defs),
+		UnlockTimes: unlockTimes(states),
+		ShowHidden:  opts.ShowHidden,
+	}
+	d := GameDetail{
+		SteamID:  steamid,
+		AppID:    appid,
+		GameName: game.Name,
+		GameIcon: game.IconURL,
+		Filter:   filter,
+		Total:    len(defs),
+		History:  history,
+	}
+	recentSince := time.Now().Add(-RecentWindow)
+	for _, def := range defs {
+		a := ex.Achievement(def.APIName, achieved[def.APIName])
+		if a.Achieved {
+			d.Done++
+		}
+		if keepForFilter(a, filter, recentSince) {
+			d.Achievements = append(d.Achievements, a)
+		}
+	}
+	if d.Total > 0 {
+		d.Pct = float64(d.Done) / float64(d.Total) * 100
+	}
+	sortChecklist(d.Achievements)
+	return d, nil
+}
+
+func keepForFilter(a compare.Achievement, f GameFilter, recentSince time.Time) bool {
+	switch f {
+	case FilterLocked:
+		return !a.Achieved
+	case FilterUnlocked:
+		return a.Achieved
+	case FilterRecent:
+		return a.Achieved && a.UnlockedAt != nil && a.UnlockedAt.After(recentSince)
+	default:
+		return true
+	}
+}
+
+// sortChecklist puts unlocked first (newest unlock first), then locked by rarity
+// (most common first, i.e. the likely next unlocks).
+func sortChecklist(xs []compare.Achievement) {
+	sort.SliceStable(xs, func(i, j int) bool {
+		a, b := xs[i], xs[j]
+		if a.Achieved != b.Achieved {
+			return a.Achieved
+		}
+		if a.Achieved {
+			return unlockedAfter(a, b)
+		}
+		return pctOrZero(a) > pctOrZero(b)
+	})
+}
+
+func unlockedAfter(a, b compare.Achievement) bool {
+	switch {
+	case a.UnlockedAt == nil:
+		return false
+	case b.UnlockedAt == nil:
+		return true
+	default:
+		return a.UnlockedAt.After(*b.UnlockedAt)
+	}
+}
+
+func pctOrZero(a compare.Achievement) float64 {
+	if a.GlobalPct == nil {
+		return 0
+	}
+	return *a.GlobalPct
+}
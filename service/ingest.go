@@ -11,7 +11,7 @@ import (
 // It computes total_done/total_available, catalog/state hashes, and persists
 // snapshot_achievements atomically with the snapshot.
 //
-// Returns the snapshot id (existing or newly inserted, per UNIQUE dedupe).
+// Returns the snapshot id (newly inserted, or the latest one when the state is unchanged).
 func IngestOneGame(ctx context.Context, repo db.Repo, steamid string, appid int64, apinames []string, achieved map[string]bool) (int64, error) {
 	totalAvail := len(apinames)
 	totalDone := 0
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/db"
+	"github.com/James-Wolfley/steam-achievement-tracker/steamapi"
+)
+
+// PrivateProfileMessage is what the UI/API report instead of an empty table.
+const PrivateProfileMessage = "profile or game details are private"
+
+// visibilityPublic is Steam's communityvisibilitystate for public profiles.
+const visibilityPublic = 3
+
+// LoadPlayer returns the stored profile for steamid, or nil if it was never refreshed.
+func LoadPlayer(ctx context.Context, repo db.Repo, steamid string) (*db.Player, error) {
+	p, err := repo.GetPlayer(ctx, steamid)
+	if errors.Is(err, db.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ProfilePrivate reports whether Steam hid the profile or its game details
+// on the last refresh. A nil (never refreshed) player is not private.
+func ProfilePrivate(p *db.Player) bool {
+	if p == nil {
+		return false
+	}
+	return p.Visibility != visibilityPublic || p.GamesPrivate
+}
+
+// playerFromSummary maps a GetPlayerSummaries entry onto the players row.
+func playerFromSummary(s steamapi.PlayerSummary, gamesPrivate bool, now time.Time) db.Player {
+	return db.Player{
+		SteamID:      s.SteamID,
+		PersonaName:  s.PersonaName,
+		AvatarURL:    firstNonEmpty(s.AvatarFull, firstNonEmpty(s.AvatarMedium, s.Avatar)),
+		ProfileURL:   s.ProfileURL,
+		Visibility:   s.CommunityVisibilityState,
+		GamesPrivate: gamesPrivate,
+		UpdatedAt:    now,
+	}
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,185 +15,298 @@ import (
 
 // RefreshStats reports what happened during a refresh run.
 type RefreshStats struct {
-	Owned         int   // total owned games returned by Steam (stable)
-	Queued        int   // enqueued after TTL cache check
-	Checked       int64 // processed AND had a non-empty schema
-	Updated       int64 // snapshots inserted (hash changed)
-	Skipped       int64 // unchanged vs latest snapshot (hash equal)
-	SkippedCached int   // skipped at queue time due to TTL cache (no HTTP call)
-	Snapshots     int64 // kept for compatibility; equals Updated
+	Owned         int            // total owned games returned by Steam (stable)
+	Queued        int            // enqueued after TTL cache check
+	Checked       int64          // processed AND had a non-empty schema
+	Updated       int64          // snapshots inserted (hash changed)
+	Skipped       int64          // unchanged vs latest snapshot (hash equal)
+	SkippedCached int            // skipped at queue time due to TTL cache (no HTTP call)
+	Processed     int64          // queued games finished so far (any outcome)
+	Failed        int            // games Steam couldn't be read for; no snapshot written
+	Failures      []GameFailure  // one per failed game, in the order they failed
+	Snapshots     int64          // kept for compatibility; equals Updated
+	Private       bool           // profile or game details are private; nothing was read
+	Pruned        RetentionStats // history removed by the retention policy afterwards
+}
+
+// GameFailure is one game a refresh couldn't read. The game keeps its previous
+// snapshot and is tried again on the next refresh.
+type GameFailure struct {
+	AppID int64
+	Name  string
+	Step  string // "schema" or "achievements"
+	Kind  string // steamapi.Kind of the error, e.g. "server" or "rate_limited"
+	Error string
+}
+
+// Done is how many owned games have been dealt with, cached skips included.
+func (s RefreshStats) Done() int { return s.SkippedCached + int(s.Processed) }
+
+// ProgressFunc receives a copy of the stats as a refresh advances. It may be
+// called from several goroutines at once.
+type ProgressFunc func(RefreshStats)
+
+// SteamClient is the part of the Steam Web API a refresh uses (steamapi.Client satisfies it).
+type SteamClient interface {
+	GetPlayerSummary(ctx context.Context, steamid string) (steamapi.PlayerSummary, error)
+	GetOwnedGames(ctx context.Context, steamid string) ([]steamapi.OwnedGame, error)
+	GetSchemaForGame(ctx context.Context, appid int64) ([]steamapi.SchemaDef, string, error)
+	GetGlobalAchievementPercentages(ctx context.Context, appid int64) (map[string]float64, error)
+	GetPlayerAchievements(ctx context.Context, steamid string, appid int64) ([]steamapi.PlayerAch, error)
 }
 
 // RefreshUserConcurrent runs a refresh with a bounded worker pool, using a short-lived
 // TTL cache for "no-achievement" games to avoid unnecessary Steam calls.
 // 'workers' ~3–5 is recommended.
-func RefreshUserConcurrent(ctx context.Context, repo db.Repo, client *steamapi.Client, steamid string, workers int) (RefreshStats, error) {
+func RefreshUserConcurrent(ctx context.Context, repo db.Repo, client SteamClient, steamid string, workers int) (RefreshStats, error) {
+	return RefreshUserWithProgress(ctx, repo, client, steamid, workers, nil)
+}
+
+// RefreshUserWithProgress is RefreshUserConcurrent, calling progress (if non-nil)
+// once the queue is built and again after every game. It returns only after
+// every worker has stopped, so nothing touches the database afterwards.
+func RefreshUserWithProgress(ctx context.Context, repo db.Repo, client SteamClient, steamid string, workers int, progress ProgressFunc) (RefreshStats, error) {
 	if workers <= 0 {
 		workers = 1
 	}
 
-	owned, err := client.GetOwnedGames(ctx, steamid)
+	now := time.Now().UTC()
+
+	// Profile first: it tells us who this is and whether anything is readable.
+	summary, err := client.GetPlayerSummary(ctx, steamid)
 	if err != nil {
 		return RefreshStats{}, err
 	}
+	owned, err := client.GetOwnedGames(ctx, steamid)
+	gamesPrivate := errors.Is(err, steamapi.ErrPrivate)
+	if err != nil && !gamesPrivate {
+		return RefreshStats{}, err
+	}
+	player := playerFromSummary(summary, gamesPrivate, now)
+	if err := repo.UpsertPlayer(ctx, player); err != nil {
+		return RefreshStats{}, err
+	}
+	if ProfilePrivate(&player) {
+		return RefreshStats{Private: true}, nil
+	}
+
 	stats := RefreshStats{Owned: len(owned)}
 	if len(owned) == 0 {
 		return stats, nil
 	}
 
-	ttl := config.SchemaTTL()
-	now := time.Now().UTC()
-
-	type job struct{ g steamapi.OwnedGame }
-	jobs := make(chan job, len(owned))
-	errs := make(chan error, workers)
+	// Everything below runs under one cancellable context: the first fatal error
+	// or the caller going away stops every worker, and nothing returns until all
+	// of them have exited.
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
 
 	// Queue phase: skip known-zero-achievement games if TTL is still fresh.
-	queued := 0
-enqueue:
+	ttl := config.SchemaTTL()
+	queue := make([]steamapi.OwnedGame, 0, len(owned))
 	for _, g := range owned {
-		select {
-		case <-ctx.Done():
-			break enqueue
-		default:
+		if err := ctx.Err(); err != nil {
+			return stats, err
 		}
 		achCount, checkedAt, cacheErr := repo.GetGameSchemaCache(ctx, g.AppID)
 		if cacheErr == nil && achCount != nil && *achCount == 0 && checkedAt != nil && now.Sub(*checkedAt) < ttl {
 			stats.SkippedCached++
 			continue
 		}
-		jobs <- job{g: g}
-		queued++
+		queue = append(queue, g)
 	}
-	close(jobs)
-	stats.Queued = queued
+	stats.Queued = len(queue)
 
-	// Workers
-	var wg sync.WaitGroup
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for j := range jobs {
-				g := j.g
+	// Sized to hold the whole queue, so filling it never blocks.
+	games := make(chan steamapi.OwnedGame, len(queue))
+	for _, g := range queue {
+		games <- g
+	}
+	close(games)
 
-				// 1) Fetch schema
-				defs, gameName, err := client.GetSchemaForGame(ctx, g.AppID)
-				// Update cache timestamp regardless (do NOT force count to 0 on errors)
-				if err != nil {
-					_ = repo.UpdateGameSchemaCache(ctx, g.AppID,
-						func() int {
-							if ach, _, e := repo.GetGameSchemaCache(ctx, g.AppID); e == nil && ach != nil {
-								return *ach
-							}
-							return 0
-						}(),
-						now,
-					)
-					continue
-				}
-				// Set cache with fresh count
-				_ = repo.UpdateGameSchemaCache(ctx, g.AppID, len(defs), now)
+	// fail records a game Steam couldn't be read for, so the run carries on
+	// without it. Cancellation, an exhausted budget and a rejected API key end
+	// the run instead: every other game would fail the same way.
+	var failMu sync.Mutex
+	fail := func(g steamapi.OwnedGame, step string, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, steamapi.ErrBudgetExhausted) || errors.Is(err, steamapi.ErrUnauthorized) {
+			return err
+		}
+		failMu.Lock()
+		defer failMu.Unlock()
+		stats.Failed++
+		stats.Failures = append(stats.Failures, GameFailure{
+			AppID: g.AppID,
+			Name:  g.Name,
+			Step:  step,
+			Kind:  steamapi.Kind(err),
+			Error: err.Error(),
+		})
+		return nil
+	}
 
-				// 2) If no achievements, skip further work
-				if len(defs) == 0 {
-					continue
-				}
+	// refreshGame fetches one game and writes a snapshot if anything changed.
+	// A failed Steam call is recorded via fail and never leads to a snapshot;
+	// DB errors are returned.
+	refreshGame := func(g steamapi.OwnedGame) error {
+		// 1) Fetch schema. On failure leave the cache alone so the game is retried.
+		defs, gameName, err := client.GetSchemaForGame(ctx, g.AppID)
+		if err != nil {
+			return fail(g, "schema", err)
+		}
+		// Set cache with fresh count
+		if err := repo.UpdateGameSchemaCache(ctx, g.AppID, len(defs), now); err != nil {
+			return err
+		}
 
-				// 3) Upsert game + catalog
-				if err := repo.UpsertGame(ctx, db.Game{AppID: g.AppID, Name: firstNonEmpty(gameName, g.Name)}); err != nil {
-					select {
-					case errs <- err:
-					default:
-					}
-					return
-				}
-				achDefs := make([]db.AchievementDef, 0, len(defs))
-				for _, d := range defs {
-					achDefs = append(achDefs, db.AchievementDef{
-						AppID:   g.AppID,
-						APIName: d.APIName,
-						Name:    d.Name,
-						Descr:   d.Descr,
-					})
-				}
-				if err := repo.UpsertAchievementDefs(ctx, achDefs); err != nil {
-					select {
-					case errs <- err:
-					default:
-					}
-					return
-				}
+		// 2) If no achievements, skip further work
+		if len(defs) == 0 {
+			return nil
+		}
 
-				// 4) Player states (private/empty allowed)
-				states, _ := client.GetPlayerAchievements(ctx, steamid, g.AppID)
+		// 3) Upsert game + catalog
+		if err := repo.UpsertGame(ctx, db.Game{AppID: g.AppID, Name: firstNonEmpty(gameName, g.Name), IconURL: g.IconURL()}); err != nil {
+			return err
+		}
+		achDefs := make([]db.AchievementDef, 0, len(defs))
+		for _, d := range defs {
+			achDefs = append(achDefs, db.AchievementDef{
+				AppID:        g.AppID,
+				APIName:      d.APIName,
+				Name:         d.Name,
+				Descr:        d.Descr,
+				Icon:         d.Icon,
+				IconGray:     d.IconGray,
+				Hidden:       d.Hidden,
+				DefaultValue: d.DefaultValue,
+			})
+		}
+		if err := repo.UpsertAchievementDefs(ctx, achDefs); err != nil {
+			return err
+		}
+		if err := refreshRarity(ctx, repo, client, g.AppID, now); err != nil {
+			return err
+		}
 
-				// Build achieved map from schema (default false) + states
-				achievedMap := make(map[string]bool, len(defs))
-				for _, d := range defs {
-					achievedMap[d.APIName] = false
-				}
-				for _, s := range states {
-					achievedMap[s.APIName] = s.Achieved
-				}
+		// 4) Player states (an empty list is fine). Without them every achievement
+		// would read as locked, so a failed call must not become a snapshot.
+		states, err := client.GetPlayerAchievements(ctx, steamid, g.AppID)
+		if err != nil {
+			return fail(g, "achievements", err)
+		}
 
-				// Precompute totals + hashes (same logic IngestOneGame will use)
-				apilist := make([]string, 0, len(defs))
-				for _, d := range defs {
-					apilist = append(apilist, d.APIName)
-				}
-				totalAvail := len(apilist)
-				totalDone := 0
-				for _, v := range achievedMap {
-					if v {
-						totalDone++
-					}
-				}
-				catHash := db.CatalogHash(g.AppID, apilist)
-				items := db.BuildSnapshotAchievements(achievedMap)
-				stateHash := db.StateHash(g.AppID, items)
-
-				// Count as processed & schema-present
-				atomic.AddInt64(&stats.Checked, 1)
-
-				// 5) If unchanged vs latest snapshot → skip insert
-				same, chkErr := unchangedAgainstLatest(ctx, repo, steamid, g.AppID, totalDone, totalAvail, catHash, stateHash)
-				if chkErr != nil {
-					select {
-					case errs <- chkErr:
-					default:
-					}
+		// Build achieved map from schema (default false) + states
+		achievedMap := make(map[string]bool, len(defs))
+		for _, d := range defs {
+			achievedMap[d.APIName] = false
+		}
+		for _, s := range states {
+			achievedMap[s.APIName] = s.Achieved
+		}
+
+		// Current per-achievement state, with real unlock times.
+		if err := repo.UpsertPlayerAchievementState(ctx, playerStateRows(steamid, g.AppID, defs, states)); err != nil {
+			return err
+		}
+
+		// Precompute totals + hashes (same logic IngestOneGame will use)
+		apilist := make([]string, 0, len(defs))
+		for _, d := range defs {
+			apilist = append(apilist, d.APIName)
+		}
+		totalAvail := len(apilist)
+		totalDone := 0
+		for _, v := range achievedMap {
+			if v {
+				totalDone++
+			}
+		}
+		catHash := db.CatalogHash(g.AppID, apilist)
+		items := db.BuildSnapshotAchievements(achievedMap)
+		stateHash := db.StateHash(g.AppID, items)
+
+		// Count as processed & schema-present
+		atomic.AddInt64(&stats.Checked, 1)
+
+		// 5) If unchanged vs latest snapshot → skip insert
+		same, chkErr := unchangedAgainstLatest(ctx, repo, steamid, g.AppID, totalDone, totalAvail, catHash, stateHash)
+		if chkErr != nil {
+			return chkErr
+		}
+		if same {
+			atomic.AddInt64(&stats.Skipped, 1)
+			return nil
+		}
+
+		// 6) Insert snapshot (+ per-snapshot achievements) atomically
+		if _, err := IngestOneGame(ctx, repo, steamid, g.AppID, apilist, achievedMap); err != nil {
+			return err
+		}
+		atomic.AddInt64(&stats.Updated, 1)
+		atomic.AddInt64(&stats.Snapshots, 1)
+		return nil
+	}
+
+	report := func() {
+		if progress == nil {
+			return
+		}
+		st := stats.snapshot()
+		failMu.Lock()
+		st.Failed, st.Failures = stats.Failed, slices.Clone(stats.Failures)
+		failMu.Unlock()
+		progress(st)
+	}
+	report()
+
+	// Workers. A worker that hits a fatal error cancels ctx with it; the others
+	// notice before their next game (or inside the Steam call they are making).
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range games {
+				if ctx.Err() != nil {
 					return
 				}
-				if same {
-					atomic.AddInt64(&stats.Skipped, 1)
-					continue
-				}
-
-				// 6) Insert snapshot (+ per-snapshot achievements) atomically
-				if _, err := IngestOneGame(ctx, repo, steamid, g.AppID, apilist, achievedMap); err != nil {
-					select {
-					case errs <- err:
-					default:
-					}
+				err := refreshGame(g)
+				atomic.AddInt64(&stats.Processed, 1)
+				report()
+				if err != nil {
+					cancel(err)
 					return
 				}
-				atomic.AddInt64(&stats.Updated, 1)
-				atomic.AddInt64(&stats.Snapshots, 1)
 			}
 		}()
 	}
-
-	// Wait for workers and surface the first error (if any)
-	done := make(chan struct{})
-	go func() { wg.Wait(); close(done) }()
-	select {
-	case <-done:
-		return stats, nil
-	case err := <-errs:
+	wg.Wait()
+	// The first error wins: a worker's, or the caller's cancellation.
+	if err := context.Cause(ctx); err != nil {
 		return stats, err
-	case <-ctx.Done():
-		return stats, ctx.Err()
+	}
+
+	// Trim old history now that this run's snapshots are in.
+	stats.Pruned, err = ApplyRetention(ctx, repo, steamid, config.SnapshotRetention(), now)
+	return stats, err
+}
+
+// snapshot copies s, reading the counters workers update atomically.
+// The other fields are only written before the workers start, except the
+// failures, which the caller copies under its lock.
+func (s *RefreshStats) snapshot() RefreshStats {
+	return RefreshStats{
+		Owned:         s.Owned,
+		Queued:        s.Queued,
+		Checked:       atomic.LoadInt64(&s.Checked),
+		Updated:       atomic.LoadInt64(&s.Updated),
+		Skipped:       atomic.LoadInt64(&s.Skipped),
+		SkippedCached: s.SkippedCached,
+		Processed:     atomic.LoadInt64(&s.Processed),
+		Snapshots:     atomic.LoadInt64(&s.Snapshots),
 	}
 }
 
@@ -211,6 +326,69 @@ func unchangedAgainstLatest(ctx context.Context, repo db.Repo, steamid string, a
 		prev.StateHash == stateHash, nil
 }
 
+// refreshRarity re-fetches global unlock percentages once the game's rarity TTL lapses,
+// or early when the catalog gained achievements we have no percentage for (new DLC).
+// Rarity is decorative, so Steam errors are ignored and retried on the next refresh;
+// only DB errors, cancellation and an exhausted budget are returned.
+func refreshRarity(ctx context.Context, repo db.Repo, client SteamClient, appid int64, now time.Time) error {
+	checkedAt, err := repo.GetGameRarityCache(ctx, appid)
+	if err != nil {
+		return err
+	}
+	if checkedAt != nil && now.Sub(*checkedAt) < config.RarityTTL() {
+		defs, err := repo.GetAchievementDefs(ctx, appid)
+		if err != nil {
+			return err
+		}
+		missing := false
+		for _, d := range defs {
+			if d.GlobalPct == nil {
+				missing = true
+				break
+			}
+		}
+		if !missing {
+			return nil
+		}
+	}
+	pcts, err := client.GetGlobalAchievementPercentages(ctx, appid)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, steamapi.ErrBudgetExhausted) {
+		return err
+	}
+	if err != nil {
+		return nil
+	}
+	return repo.UpdateAchievementRarity(ctx, appid, pcts, now)
+}
+
+// playerStateRows maps Steam's per-player achievements onto the catalog (defs).
+// Achievements Steam didn't report are stored as locked; unknown apinames are dropped.
+func playerStateRows(steamid string, appid int64, defs []steamapi.SchemaDef, states []steamapi.PlayerAch) []db.PlayerAchievementState {
+	byName := make(map[string]steamapi.PlayerAch, len(states))
+	for _, s := range states {
+		byName[s.APIName] = s
+	}
+	rows := make([]db.PlayerAchievementState, 0, len(defs))
+	for _, d := range defs {
+		st := byName[d.APIName]
+		row := db.PlayerAchievementState{
+			SteamID:  steamid,
+			AppID:    appid,
+			APIName:  d.APIName,
+			Achieved: st.Achieved,
+		}
+		if st.Achieved && st.UnlockTs > 0 {
+			t := time.Unix(st.UnlockTs, 0).UTC()
+			row.UnlockTime = &t
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
 func firstNonEmpty(a, b string) string {
 	if a != "" {
 		return a
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/db"
+	"github.com/James-Wolfley/steam-achievement-tracker/steamapi"
+)
+
+// fatalAppID is the game blockingSteam fails with an exhausted budget.
+const fatalAppID = 1
+
+// blockingSteam hangs in GetSchemaForGame for every game but fatalAppID until the
+// refresh is cancelled, then answers late as a slow Steam would. fatalAppID fails
+// once the others are all stuck.
+type blockingSteam struct {
+	games   []steamapi.OwnedGame
+	blocked chan struct{} // one send per hanging call
+	active  atomic.Int32  // Steam calls in progress
+}
+
+func (s *blockingSteam) GetPlayerSummary(ctx context.Context, steamid string) (steamapi.PlayerSummary, error) {
+	return steamapi.PlayerSummary{SteamID: steamid, CommunityVisibilityState: 3}, nil
+}
+
+func (s *blockingSteam) GetOwnedGames(ctx context.Context, steamid string) ([]steamapi.OwnedGame, error) {
+	return s.games, nil
+}
+
+func (s *blockingSteam) GetSchemaForGame(ctx context.Context, appid int64) ([]steamapi.SchemaDef, string, error) {
+	s.active.Add(1)
+	defer s.active.Add(-1)
+	if appid == fatalAppID {
+		for range s.games[1:] {
+			<-s.blocked
+		}
+		return nil, "", steamapi.ErrBudgetExhausted
+	}
+	s.blocked <- struct{}{}
+	<-ctx.Done()
+	time.Sleep(50 * time.Millisecond)
+	return []steamapi.SchemaDef{{APIName: "ACH"}}, "Late", nil
+}
+
+func (s *blockingSteam) GetGlobalAchievementPercentages(ctx context.Context, appid int64) (map[string]float64, error) {
+	return nil, ctx.Err()
+}
+
+func (s *blockingSteam) GetPlayerAchievements(ctx context.Context, steamid string, appid int64) ([]steamapi.PlayerAch, error) {
+	return nil, ctx.Err()
+}
+
+// writeWatcher counts repo writes made after returned is set.
+type writeWatcher struct {
+	db.Repo
+	returned atomic.Bool
+	late     atomic.Int32
+}
+
+func (w *writeWatcher) write() {
+	if w.returned.Load() {
+		w.late.Add(1)
+	}
+}
+
+func (w *writeWatcher) UpsertGame(ctx context.Context, g db.Game) error {
+	w.write()
+	return w.Repo.UpsertGame(ctx, g)
+}
+
+func (w *writeWatcher) UpsertAchievementDefs(ctx context.Context, defs []db.AchievementDef) error {
+	w.write()
+	return w.Repo.UpsertAchievementDefs(ctx, defs)
+}
+
+func (w *writeWatcher) UpdateGameSchemaCache(ctx context.Context, appid int64, achCount int, checkedAt time.Time) error {
+	w.write()
+	return w.Repo.UpdateGameSchemaCache(ctx, appid, achCount, checkedAt)
+}
+
+func (w *writeWatcher) UpdateAchievementRarity(ctx context.Context, appid int64, pcts map[string]float64, checkedAt time.Time) error {
+	w.write()
+	return w.Repo.UpdateAchievementRarity(ctx, appid, pcts, checkedAt)
+}
+
+func (w *writeWatcher) UpsertPlayerAchievementState(ctx context.Context, rows []db.PlayerAchievementState) error {
+	w.write()
+	return w.Repo.UpsertPlayerAchievementState(ctx, rows)
+}
+
+func (w *writeWatcher) InsertSnapshot(ctx context.Context, in db.SnapshotInsert) (int64, error) {
+	w.write()
+	return w.Repo.InsertSnapshot(ctx, in)
+}
+
+func TestRefreshFatalErrorStopsAllWorkers(t *testing.T) {
+	const workers = 4
+	repo := &writeWatcher{Repo: openTestDB(t)}
+	steam := &blockingSteam{blocked: make(chan struct{})}
+	for appid := int64(1); appid <= workers; appid++ {
+		steam.games = append(steam.games, steamapi.OwnedGame{AppID: appid, Name: "Game"})
+	}
+	baseline := runtime.NumGoroutine()
+
+	var progressMu sync.Mutex
+	var lastProgress RefreshStats
+	stats, err := RefreshUserWithProgress(context.Background(), repo, steam, testSteamID, workers, func(st RefreshStats) {
+		progressMu.Lock()
+		lastProgress = st
+		progressMu.Unlock()
+	})
+	repo.returned.Store(true)
+
+	if !errors.Is(err, steamapi.ErrBudgetExhausted) {
+		t.Fatalf("err = %v, want ErrBudgetExhausted", err)
+	}
+	if n := steam.active.Load(); n != 0 {
+		t.Errorf("%d Steam calls still running after return", n)
+	}
+	if stats.Processed != workers {
+		t.Errorf("Processed = %d, want %d (every worker finished its game)", stats.Processed, workers)
+	}
+	if stats.Failed != 0 {
+		t.Errorf("Failed = %d; a fatal error is not a per-game failure", stats.Failed)
+	}
+
+	// Anything still running would write (and report) once its late answer arrives.
+	time.Sleep(150 * time.Millisecond)
+	if n := repo.late.Load(); n != 0 {
+		t.Errorf("%d repo writes after return", n)
+	}
+	progressMu.Lock()
+	if lastProgress.Processed != workers {
+		t.Errorf("last progress Processed = %d, want %d", lastProgress.Processed, workers)
+	}
+	progressMu.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > baseline {
+		buf := make([]byte, 1<<16)
+		t.Errorf("%d goroutines, want at most %d:\n%s", n, baseline, buf[:runtime.Stack(buf, true)])
+	}
+}
+
+func TestRefreshCallerCancelStopsAllWorkers(t *testing.T) {
+	const workers = 3
+	repo := &writeWatcher{Repo: openTestDB(t)}
+	// No fatal game: everything hangs until the caller gives up.
+	steam := &blockingSteam{blocked: make(chan struct{}, workers)}
+	for appid := int64(2); appid < 2+workers; appid++ {
+		steam.games = append(steam.games, steamapi.OwnedGame{AppID: appid, Name: "Game"})
+	}
+	baseline := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for i := 0; i < workers; i++ {
+			<-steam.blocked
+		}
+		cancel()
+	}()
+	_, err := RefreshUserWithProgress(ctx, repo, steam, testSteamID, workers, nil)
+	repo.returned.Store(true)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if n := steam.active.Load(); n != 0 {
+		t.Errorf("%d Steam calls still running after return", n)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if n := repo.late.Load(); n != 0 {
+		t.Errorf("%d repo writes after return", n)
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > baseline {
+		t.Errorf("%d goroutines, want at most %d", n, baseline)
+	}
+}
+
+// failingSteam fails GetSchemaForGame for every game with err.
+type failingSteam struct {
+	blockingSteam
+	err   error
+	calls atomic.Int32
+}
+
+func (s *failingSteam) GetSchemaForGame(ctx context.Context, appid int64) ([]steamapi.SchemaDef, string, error) {
+	s.calls.Add(1)
+	return nil, "", s.err
+}
+
+func TestRefreshFatalSteamErrors(t *testing.T) {
+	const games = 5
+	tests := []struct {
+		name   string
+		err    error
+		fatal  bool
+		calls  int32
+		failed int
+	}{
+		{"unauthorized", fmt.Errorf("schema: %w", steamapi.ErrUnauthorized), true, 1, 0},
+		{"budget", steamapi.ErrBudgetExhausted, true, 1, 0},
+		{"server error", steamapi.ErrServer, false, games, games},
+		{"not found", steamapi.ErrNotFound, false, games, games},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steam := &failingSteam{err: tt.err}
+			for appid := int64(1); appid <= games; appid++ {
+				steam.games = append(steam.games, steamapi.OwnedGame{AppID: appid, Name: "Game"})
+			}
+			stats, err := RefreshUserConcurrent(context.Background(), openTestDB(t), steam, testSteamID, 1)
+			if tt.fatal != (err != nil) || (tt.fatal && !errors.Is(err, tt.err)) {
+				t.Errorf("err = %v, want fatal %v", err, tt.fatal)
+			}
+			if got := steam.calls.Load(); got != tt.calls {
+				t.Errorf("%d schema calls, want %d", got, tt.calls)
+			}
+			if stats.Failed != tt.failed {
+				t.Errorf("Failed = %d, want %d", stats.Failed, tt.failed)
+			}
+		})
+	}
+}
+
+// rarityFailingSteam serves a one-achievement schema and fails the rarity call with err.
+type rarityFailingSteam struct {
+	blockingSteam
+	err   error
+	calls atomic.Int32
+}
+
+func (s *rarityFailingSteam) GetSchemaForGame(ctx context.Context, appid int64) ([]steamapi.SchemaDef, string, error) {
+	return []steamapi.SchemaDef{{APIName: "ACH"}}, "Game", nil
+}
+
+func (s *rarityFailingSteam) GetGlobalAchievementPercentages(ctx context.Context, appid int64) (map[string]float64, error) {
+	s.calls.Add(1)
+	return nil, s.err
+}
+
+func TestRefreshRarityErrors(t *testing.T) {
+	const games = 3
+	tests := []struct {
+		name  string
+		err   error
+		fatal bool
+		calls int32
+	}{
+		{"budget ends the run", steamapi.ErrBudgetExhausted, true, 1},
+		{"server error is ignored", steamapi.ErrServer, false, games},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steam := &rarityFailingSteam{err: tt.err}
+			for appid := int64(1); appid <= games; appid++ {
+				steam.games = append(steam.games, steamapi.OwnedGame{AppID: appid, Name: "Game"})
+			}
+			stats, err := RefreshUserConcurrent(context.Background(), openTestDB(t), steam, testSteamID, 1)
+			if tt.fatal != errors.Is(err, tt.err) || (!tt.fatal && err != nil) {
+				t.Errorf("err = %v, want fatal %v", err, tt.fatal)
+			}
+			if got := steam.calls.Load(); got != tt.calls {
+				t.Errorf("%d rarity calls, want %d", got, tt.calls)
+			}
+			if !tt.fatal && stats.Updated != games {
+				t.Errorf("Updated = %d, want %d", stats.Updated, games)
+			}
+		})
+	}
+}
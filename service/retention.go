@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/config"
+	"github.com/James-Wolfley/steam-achievement-tracker/db"
+)
+
+// retentionMilestones are completion percentages; a snapshot that crosses one
+// (upwards, relative to the snapshot before it) is never pruned.
+var retentionMilestones = []int{25, 50, 75, 100}
+
+// RetentionStats reports what a retention pass removed.
+type RetentionStats struct {
+	Games        int   // games that lost at least one snapshot
+	Snapshots    int64 // snapshots removed
+	Achievements int64 // snapshot_achievements rows removed with them
+}
+
+// Rows is the total number of database rows removed.
+func (s RetentionStats) Rows() int64 { return s.Snapshots + s.Achievements }
+
+// ApplyRetention prunes the snapshot history of every game steamid has, per policy.
+// A disabled policy is a no-op.
+func ApplyRetention(ctx context.Context, repo db.Repo, steamid string, policy config.Retention, now time.Time) (RetentionStats, error) {
+	var stats RetentionStats
+	if !policy.Enabled {
+		return stats, nil
+	}
+	// One read and one delete for the whole account, however many games it has.
+	byApp, err := repo.ListSnapshotsForUser(ctx, steamid)
+	if err != nil {
+		return stats, err
+	}
+	var drop []int64
+	for _, snaps := range byApp {
+		if ids := snapshotsToPrune(snaps, policy, now); len(ids) > 0 {
+			stats.Games++
+			drop = append(drop, ids...)
+		}
+	}
+	if len(drop) == 0 {
+		return stats, nil
+	}
+	res, err := repo.PruneSnapshots(ctx, steamid, drop)
+	if err != nil {
+		return RetentionStats{}, fmt.Errorf("prune snapshots: %w", err)
+	}
+	stats.Snapshots, stats.Achievements = res.Snapshots, res.Achievements
+	return stats, nil
+}
+
+// snapshotsToPrune returns the ids policy drops from one game's history (oldest first).
+// Kept: the oldest snapshot and the newest two (so comparisons are unaffected),
+// everything younger than KeepAll, the newest snapshot of each ISO week until
+// Weekly and of each calendar month after that, and every milestone crossing.
+func snapshotsToPrune(snaps []db.Snapshot, policy config.Retention, now time.Time) []int64 {
+	n := len(snaps)
+	if n <= 3 {
+		return nil
+	}
+	keep := make([]bool, n)
+	keep[0], keep[n-2], keep[n-1] = true, true, true
+
+	// Walk newest first so the first snapshot seen in a bucket is its newest.
+	seen := map[string]bool{}
+	for i := n - 1; i >= 0; i-- {
+		t := snaps[i].TakenAt.UTC()
+		var bucket string
+		switch age := now.Sub(t); {
+		case age < policy.KeepAll:
+			keep[i] = true
+			continue
+		case age < policy.Weekly:
+			y, w := t.ISOWeek()
+			bucket = fmt.Sprintf("w%d-%02d", y, w)
+		default:
+			bucket = t.Format("m2006-01")
+		}
+		if !seen[bucket] {
+			seen[bucket] = true
+			keep[i] = true
+		}
+	}
+
+	for i := 1; i < n; i++ {
+		if crossesMilestone(snaps[i-1], snaps[i]) {
+			keep[i] = true
+		}
+	}
+
+	var drop []int64
+	for i, s := range snaps {
+		if !keep[i] {
+			drop = append(drop, s.ID)
+		}
+	}
+	return drop
+}
+
+// crossesMilestone reports whether curr's completion reached a milestone prev hadn't.
+func crossesMilestone(prev, curr db.Snapshot) bool {
+	for _, m := range retentionMilestones {
+		if reached(curr, m) && !reached(prev, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func reached(s db.Snapshot, pct int) bool {
+	return s.TotalAvailable > 0 && s.TotalDone*100 >= pct*s.TotalAvailable
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/config"
+	"github.com/James-Wolfley/steam-achievement-tracker/db"
+)
+
+// pruneCounter counts PruneSnapshots calls.
+type pruneCounter struct {
+	db.Repo
+	calls int
+}
+
+func (p *pruneCounter) PruneSnapshots(ctx context.Context, steamid string, ids []int64) (db.PruneResult, error) {
+	p.calls++
+	return p.Repo.PruneSnapshots(ctx, steamid, ids)
+}
+
+func TestApplyRetentionPrunesEveryGameAtOnce(t *testing.T) {
+	ctx := context.Background()
+	repo := &pruneCounter{Repo: openTestDB(t)}
+	base := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	seed(t, repo, base, 4, 10, 40)
+	policy := config.Retention{Enabled: true, KeepAll: 3 * 24 * time.Hour, Weekly: 14 * 24 * time.Hour}
+
+	appids, err := repo.ListAppIDsWithSnapshots(ctx, testSteamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64][]int64{} // appid -> ids kept
+	var dropped int64
+	for _, appid := range appids {
+		snaps, err := repo.ListSnapshots(ctx, testSteamID, appid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		drop := map[int64]bool{}
+		for _, id := range snapshotsToPrune(snaps, policy, base) {
+			drop[id] = true
+		}
+		for _, s := range snaps {
+			if !drop[s.ID] {
+				want[appid] = append(want[appid], s.ID)
+			}
+		}
+		dropped += int64(len(drop))
+	}
+	if dropped == 0 {
+		t.Fatal("seed gives the policy nothing to prune")
+	}
+
+	stats, err := ApplyRetention(ctx, repo, testSteamID, policy, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.calls != 1 {
+		t.Errorf("PruneSnapshots called %d times, want 1", repo.calls)
+	}
+	if stats.Games != len(appids) || stats.Snapshots != dropped || stats.Achievements == 0 {
+		t.Errorf("stats = %+v, want %d games and %d snapshots", stats, len(appids), dropped)
+	}
+	for _, appid := range appids {
+		snaps, err := repo.ListSnapshots(ctx, testSteamID, appid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(snaps) != len(want[appid]) {
+			t.Fatalf("app %d kept %d snapshots, want %d", appid, len(snaps), len(want[appid]))
+		}
+		for i, s := range snaps {
+			if s.ID != want[appid][i] {
+				t.Errorf("app %d kept snapshot %d, want %d", appid, s.ID, want[appid][i])
+			}
+		}
+	}
+
+	// A second pass has nothing left to do.
+	if stats, err := ApplyRetention(ctx, repo, testSteamID, policy, base); err != nil || stats != (RetentionStats{}) {
+		t.Errorf("second pass = %+v, %v; want nothing", stats, err)
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/db"
+)
+
+// DefaultSummaryPeriod is how far back the summary delta looks when no start is given.
+const DefaultSummaryPeriod = 30 * 24 * time.Hour
+
+// SummaryTotals is an account-wide roll-up over one snapshot per game.
+type SummaryTotals struct {
+	Games         int     // games with achievements that have a snapshot
+	Earned        int     // achievements unlocked across all games
+	Available     int     // achievements available across all games
+	Perfect       int     // games at 100%
+	Started       int     // games with at least one unlock but not finished
+	AvgCompletion float64 // Steam-style: mean completion % over games with at least one unlock
+}
+
+// Summary compares the account at Until (latest when zero) with the account at Since.
+type Summary struct {
+	SteamID  string
+	Since    time.Time
+	Until    time.Time // zero = latest snapshots
+	Current  SummaryTotals
+	Previous SummaryTotals
+	Delta    SummaryTotals // Current - Previous
+}
+
+// BuildSummary rolls up the newest snapshot of every game at or before until (latest if
+// zero) and the same as of since (DefaultSummaryPeriod ago if zero), plus the difference.
+func BuildSummary(ctx context.Context, repo db.Repo, steamid string, since, until time.Time) (Summary, error) {
+	if since.IsZero() {
+		since = time.Now().UTC().Add(-DefaultSummaryPeriod)
+	}
+	prev, curr, err := repo.GetSnapshotsInRange(ctx, steamid, since, until)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	s := Summary{
+		SteamID:  steamid,
+		Since:    since,
+		Until:    until,
+		Current:  summarize(curr),
+		Previous: summarize(prev),
+	}
+	s.Delta = SummaryTotals{
+		Games:         s.Current.Games - s.Previous.Games,
+		Earned:        s.Current.Earned - s.Previous.Earned,
+		Available:     s.Current.Available - s.Previous.Available,
+		Perfect:       s.Current.Perfect - s.Previous.Perfect,
+		Started:       s.Current.Started - s.Previous.Started,
+		AvgCompletion: s.Current.AvgCompletion - s.Previous.AvgCompletion,
+	}
+	return s, nil
+}
+
+// summarize totals one snapshot per game. Games without achievements are ignored.
+func summarize(snaps []db.Snapshot) SummaryTotals {
+	var t SummaryTotals
+	var pctSum float64
+	var withUnlocks int
+	for _, s := range snaps {
+		if s.TotalAvailable <= 0 {
+			continue
+		}
+		t.Games++
+		t.Earned += s.TotalDone
+		t.Available += s.TotalAvailable
+		switch {
+		case s.TotalDone >= s.TotalAvailable:
+			t.Perfect++
+		case s.TotalDone > 0:
+			t.Started++
+		}
+		if s.TotalDone > 0 {
+			withUnlocks++
+			pctSum += float64(s.TotalDone) / float64(s.TotalAvailable) * 100
+		}
+	}
+	if withUnlocks > 0 {
+		t.AvgCompletion = pctSum / float64(withUnlocks)
+	}
+	return t
+}
@@ -5,27 +5,61 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/config"
 )
 
 type Client struct {
-	key    string
-	client *http.Client
+	key     string
+	baseURL string
+	client  *http.Client
+	retry   RetryPolicy
+	limiter *Limiter // nil = unlimited
+}
+
+// RetryPolicy bounds how doJSON retries 429/5xx responses and transport errors.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first (>= 1)
+	BaseDelay   time.Duration // first backoff step; doubles per attempt
+	MaxDelay    time.Duration // cap for a single backoff step
+	MaxWait     time.Duration // longest Retry-After we are willing to honour
 }
 
-// New reads STEAM_API_KEY and returns a client with sensible timeouts.
+// DefaultRetryPolicy is used by New and NewWithBaseURL.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+	MaxWait:     60 * time.Second,
+}
+
+// New reads STEAM_API_KEY and STEAM_API_BASE_URL and returns a client with sensible timeouts.
+// The key is only required when talking to the real Steam API.
+// All clients from New share SharedLimiter, so parallel refreshes can't multiply our call rate.
 func New() (*Client, error) {
 	key := os.Getenv("STEAM_API_KEY")
-	if key == "" {
+	baseURL := config.SteamAPIBaseURL()
+	if key == "" && baseURL == config.DefaultSteamAPIBaseURL {
 		return nil, errors.New("STEAM_API_KEY not set")
 	}
+	return NewWithBaseURL(key, baseURL).WithLimiter(SharedLimiter()), nil
+}
+
+// NewWithBaseURL returns a client that talks to baseURL (e.g. a fake Steam server).
+func NewWithBaseURL(key, baseURL string) *Client {
 	return &Client{
-		key: key,
+		key:     key,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		retry:   DefaultRetryPolicy,
 		client: &http.Client{
 			Timeout: 20 * time.Second,
 			Transport: &http.Transport{
@@ -38,14 +72,31 @@ func New() (*Client, error) {
 				MaxConnsPerHost:       10,
 			},
 		},
-	}, nil
+	}
+}
+
+// WithRetryPolicy returns a copy of c using p.
+func (c *Client) WithRetryPolicy(p RetryPolicy) *Client {
+	cp := *c
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	cp.retry = p
+	return &cp
+}
+
+// WithLimiter returns a copy of c that waits on l before every HTTP attempt.
+func (c *Client) WithLimiter(l *Limiter) *Client {
+	cp := *c
+	cp.limiter = l
+	return &cp
 }
 
 // ------------ API shapes ------------
 
 type OwnedGamesResp struct {
 	Response struct {
-		GameCount int         `json:"game_count"`
+		GameCount *int        `json:"game_count"` // absent when game details are private
 		Games     []OwnedGame `json:"games"`
 	} `json:"response"`
 }
@@ -55,6 +106,15 @@ type OwnedGame struct {
 	Name                     string `json:"name"`
 	HasCommunityVisibleStats bool   `json:"has_community_visible_stats"`
 	PlaytimeForever          int    `json:"playtime_forever"`
+	ImgIconURL               string `json:"img_icon_url"` // icon hash, see IconURL
+}
+
+// IconURL returns the full URL of the game's icon ("" if Steam gave none).
+func (g OwnedGame) IconURL() string {
+	if g.ImgIconURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/steamcommunity/public/images/apps/%d/%s.jpg", config.SteamMediaBaseURL(), g.AppID, g.ImgIconURL)
 }
 
 type SchemaForGameResp struct {
@@ -63,9 +123,13 @@ type SchemaForGameResp struct {
 		GameVersion        string `json:"gameVersion"`
 		AvailableGameStats struct {
 			Achievements []struct {
-				Name        string `json:"name"`        // apiname
-				DisplayName string `json:"displayName"` // human name
-				Description string `json:"description"`
+				Name         string `json:"name"`        // apiname
+				DisplayName  string `json:"displayName"` // human name
+				Description  string `json:"description"`
+				DefaultValue int    `json:"defaultvalue"`
+				Hidden       int    `json:"hidden"` // 0/1
+				Icon         string `json:"icon"`
+				IconGray     string `json:"icongray"`
 			} `json:"achievements"`
 		} `json:"availableGameStats"`
 	} `json:"game"`
@@ -84,11 +148,66 @@ type PlayerAchievementsResp struct {
 	} `json:"playerstats"`
 }
 
+type GlobalAchievementPercentagesResp struct {
+	AchievementPercentages struct {
+		Achievements []struct {
+			Name    string    `json:"name"`
+			Percent flexFloat `json:"percent"`
+		} `json:"achievements"`
+	} `json:"achievementpercentages"`
+}
+
+type PlayerSummariesResp struct {
+	Response struct {
+		Players []PlayerSummary `json:"players"`
+	} `json:"response"`
+}
+
+type PlayerSummary struct {
+	SteamID                  string `json:"steamid"`
+	PersonaName              string `json:"personaname"`
+	ProfileURL               string `json:"profileurl"`
+	Avatar                   string `json:"avatar"`
+	AvatarMedium             string `json:"avatarmedium"`
+	AvatarFull               string `json:"avatarfull"`
+	CommunityVisibilityState int    `json:"communityvisibilitystate"` // 1 private, 3 public
+	ProfileState             int    `json:"profilestate"`             // 1 = community profile set up
+}
+
+type ResolveVanityURLResp struct {
+	Response struct {
+		SteamID string `json:"steamid"`
+		Success int    `json:"success"` // 1 = match, 42 = no match
+		Message string `json:"message"`
+	} `json:"response"`
+}
+
 // ------------ Calls ------------
 
+// ResolveVanityURL maps a custom profile URL name to a SteamID64.
+// Returns an error wrapping ErrNotFound when no profile uses that name.
+func (c *Client) ResolveVanityURL(ctx context.Context, vanity string) (string, error) {
+	u := c.endpoint("/ISteamUser/ResolveVanityURL/v1/")
+	q := url.Values{}
+	q.Set("key", c.key)
+	q.Set("vanityurl", vanity)
+	q.Set("url_type", "1") // individual profile
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u+"?"+q.Encode(), nil)
+
+	var out ResolveVanityURLResp
+	if err := c.doJSON(req, &out); err != nil {
+		return "", err
+	}
+	if out.Response.Success != 1 || out.Response.SteamID == "" {
+		return "", fmt.Errorf("%w: no profile with custom URL %q", ErrNotFound, vanity)
+	}
+	return out.Response.SteamID, nil
+}
+
 // GetOwnedGames returns the user's owned games, including names.
+// Returns ErrPrivate when the profile's game details are hidden.
 func (c *Client) GetOwnedGames(ctx context.Context, steamid string) ([]OwnedGame, error) {
-	u := "https://api.steampowered.com/IPlayerService/GetOwnedGames/v1/"
+	u := c.endpoint("/IPlayerService/GetOwnedGames/v1/")
 	q := url.Values{}
 	q.Set("key", c.key)
 	q.Set("steamid", steamid)
@@ -100,12 +219,36 @@ func (c *Client) GetOwnedGames(ctx context.Context, steamid string) ([]OwnedGame
 	if err := c.doJSON(req, &out); err != nil {
 		return nil, err
 	}
+	if out.Response.GameCount == nil {
+		return nil, ErrPrivate
+	}
 	return out.Response.Games, nil
 }
 
+// GetPlayerSummary returns the public profile for steamid.
+// Returns an error wrapping ErrNotFound if Steam doesn't know the account.
+func (c *Client) GetPlayerSummary(ctx context.Context, steamid string) (PlayerSummary, error) {
+	u := c.endpoint("/ISteamUser/GetPlayerSummaries/v2/")
+	q := url.Values{}
+	q.Set("key", c.key)
+	q.Set("steamids", steamid)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u+"?"+q.Encode(), nil)
+
+	var out PlayerSummariesResp
+	if err := c.doJSON(req, &out); err != nil {
+		return PlayerSummary{}, err
+	}
+	for _, p := range out.Response.Players {
+		if p.SteamID == steamid {
+			return p, nil
+		}
+	}
+	return PlayerSummary{}, fmt.Errorf("%w: no profile for steamid %s", ErrNotFound, steamid)
+}
+
 // GetSchemaForGame lists achievement defs for an app. Some games have no achievements.
 func (c *Client) GetSchemaForGame(ctx context.Context, appid int64) (defs []SchemaDef, gameName string, err error) {
-	u := "https://api.steampowered.com/ISteamUserStats/GetSchemaForGame/v2/"
+	u := c.endpoint("/ISteamUserStats/GetSchemaForGame/v2/")
 	q := url.Values{}
 	q.Set("key", c.key)
 	q.Set("appid", strconv.FormatInt(appid, 10))
@@ -118,18 +261,42 @@ func (c *Client) GetSchemaForGame(ctx context.Context, appid int64) (defs []Sche
 	gameName = raw.Game.GameName
 	for _, a := range raw.Game.AvailableGameStats.Achievements {
 		defs = append(defs, SchemaDef{
-			APIName: a.Name,
-			Name:    emptyFallback(a.DisplayName, a.Name),
-			Descr:   a.Description,
+			APIName:      a.Name,
+			Name:         emptyFallback(a.DisplayName, a.Name),
+			Descr:        a.Description,
+			Icon:         a.Icon,
+			IconGray:     a.IconGray,
+			Hidden:       a.Hidden == 1,
+			DefaultValue: a.DefaultValue,
 		})
 	}
 	return defs, gameName, nil
 }
 
+// GetGlobalAchievementPercentages returns the share of players (0..100) who unlocked
+// each achievement of appid, keyed by apiname.
+func (c *Client) GetGlobalAchievementPercentages(ctx context.Context, appid int64) (map[string]float64, error) {
+	u := c.endpoint("/ISteamUserStats/GetGlobalAchievementPercentagesForApp/v2/")
+	q := url.Values{}
+	q.Set("gameid", strconv.FormatInt(appid, 10))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u+"?"+q.Encode(), nil)
+
+	var raw GlobalAchievementPercentagesResp
+	if err := c.doJSON(req, &raw); err != nil {
+		return nil, err
+	}
+	out := make(map[string]float64, len(raw.AchievementPercentages.Achievements))
+	for _, a := range raw.AchievementPercentages.Achievements {
+		out[a.Name] = float64(a.Percent)
+	}
+	return out, nil
+}
+
 // GetPlayerAchievements returns achievement states for a user/app.
-// If the game has no achievements or stats are hidden, Steam may return success=false.
+// If the game has no achievements or stats are hidden, Steam may return success=false;
+// a 400 "no stats" answer (the player never started the game) is an empty list.
 func (c *Client) GetPlayerAchievements(ctx context.Context, steamid string, appid int64) ([]PlayerAch, error) {
-	u := "https://api.steampowered.com/ISteamUserStats/GetPlayerAchievements/v1/"
+	u := c.endpoint("/ISteamUserStats/GetPlayerAchievements/v1/")
 	q := url.Values{}
 	q.Set("key", c.key)
 	q.Set("steamid", steamid)
@@ -137,7 +304,12 @@ func (c *Client) GetPlayerAchievements(ctx context.Context, steamid string, appi
 	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, u+"?"+q.Encode(), nil)
 
 	var raw PlayerAchievementsResp
-	if err := c.doJSON(req, &raw); err != nil {
+	err := c.doJSON(req, &raw)
+	if errors.Is(err, ErrNoStats) {
+		// Steam's answer when the player has no stats for the game yet: nothing unlocked.
+		return []PlayerAch{}, nil
+	}
+	if err != nil {
 		return nil, err
 	}
 	ach := make([]PlayerAch, 0, len(raw.Playerstats.Achievements))
@@ -154,9 +326,13 @@ func (c *Client) GetPlayerAchievements(ctx context.Context, steamid string, appi
 // ------------ Types used by service ------------
 
 type SchemaDef struct {
-	APIName string
-	Name    string
-	Descr   string
+	APIName      string
+	Name         string
+	Descr        string // Steam often leaves this empty for hidden achievements
+	Icon         string // unlocked icon URL
+	IconGray     string // locked icon URL
+	Hidden       bool
+	DefaultValue int
 }
 
 type PlayerAch struct {
@@ -167,17 +343,106 @@ type PlayerAch struct {
 
 // ------------ internals ------------
 
+func (c *Client) endpoint(path string) string {
+	return c.baseURL + path
+}
+
+// doJSON performs req and decodes a 2xx JSON body into v.
+// Every attempt draws from the client's limiter.
+// 429, 5xx and transport errors are retried with exponential backoff + full jitter,
+// honouring Retry-After. Other statuses fail immediately with an *HTTPError.
 func (c *Client) doJSON(req *http.Request, v any) error {
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return err
+	ctx := req.Context()
+	p := c.retry
+	for attempt := 1; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		resp, err := c.client.Do(req.Clone(ctx))
+		if err != nil {
+			if ctx.Err() != nil || attempt >= p.MaxAttempts {
+				return err
+			}
+			if werr := sleepCtx(ctx, backoff(p, attempt)); werr != nil {
+				return werr
+			}
+			continue
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			err := json.NewDecoder(resp.Body).Decode(v)
+			resp.Body.Close()
+			return err
+		}
+
+		herr := newHTTPError(resp, attempt)
+		drainClose(resp.Body)
+		if !retryableStatus(resp.StatusCode) || attempt >= p.MaxAttempts {
+			return herr
+		}
+		wait := backoff(p, attempt)
+		if herr.RetryAfter > 0 {
+			if herr.RetryAfter > p.MaxWait {
+				return herr // caller decides; don't park a worker for minutes
+			}
+			wait = herr.RetryAfter
+		}
+		if werr := sleepCtx(ctx, wait); werr != nil {
+			return werr
+		}
+	}
+}
+
+// backoff returns a full-jitter delay in [0, min(MaxDelay, BaseDelay*2^(attempt-1))).
+func backoff(p RetryPolicy, attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(d)))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// drainClose reads a bounded amount of the body so the connection can be reused.
+func drainClose(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	_ = body.Close()
+}
+
+// flexFloat decodes a JSON number or a quoted number (Steam sends both for percentages).
+type flexFloat float64
+
+func (f *flexFloat) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*f = 0
+		return nil
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("steam http %d", resp.StatusCode)
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("percent %s: %w", b, err)
 	}
-	dec := json.NewDecoder(resp.Body)
-	return dec.Decode(v)
+	*f = flexFloat(v)
+	return nil
 }
 
 func emptyFallback(s, fallback string) string {
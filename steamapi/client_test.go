@@ -0,0 +1,177 @@
+package steamapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/steamapi/fakesteam"
+)
+
+const testSteamID = "76561197960287930"
+
+// fastRetry keeps backoff sleeps negligible; MaxWait allows a 1s Retry-After.
+var fastRetry = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    2 * time.Millisecond,
+	MaxWait:     time.Second,
+}
+
+// newFakeClient returns a client talking to a fake Steam server running rules.
+func newFakeClient(t *testing.T, rules ...fakesteam.Rule) (*Client, *fakesteam.Server) {
+	t.Helper()
+	srv, err := fakesteam.New("../testdata/fakesteam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.SetScenario(fakesteam.Scenario{Rules: rules})
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return NewWithBaseURL("", ts.URL).WithRetryPolicy(fastRetry), srv
+}
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     fakesteam.Rule
+		kind     error // nil: an *HTTPError matching none of the kinds
+		status   int
+		attempts int
+	}{
+		{"429 retried", fakesteam.Rule{Status: 429}, ErrRateLimited, 429, 3},
+		{"500 retried", fakesteam.Rule{Status: 500}, ErrServer, 500, 3},
+		{"503 retried", fakesteam.Rule{Status: 503}, ErrServer, 503, 3},
+		{"401", fakesteam.Rule{Status: 401}, ErrUnauthorized, 401, 1},
+		{"403 without body", fakesteam.Rule{Status: 403}, ErrUnauthorized, 403, 1},
+		{"403 not public", fakesteam.Rule{Private: true}, ErrPrivate, 403, 1},
+		{"404", fakesteam.Rule{Status: 404}, ErrNotFound, 404, 1},
+		{"400", fakesteam.Rule{Status: 400}, nil, 400, 1},
+	}
+	kinds := []error{ErrRateLimited, ErrServer, ErrUnauthorized, ErrPrivate, ErrNotFound, ErrNoStats}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.Endpoint = fakesteam.GetPlayerAchievements
+			c, srv := newFakeClient(t, tt.rule)
+			_, err := c.GetPlayerAchievements(context.Background(), testSteamID, 620)
+
+			var herr *HTTPError
+			if !errors.As(err, &herr) {
+				t.Fatalf("err = %v, want *HTTPError", err)
+			}
+			if herr.StatusCode != tt.status || herr.Attempts != tt.attempts {
+				t.Errorf("status %d after %d attempts, want %d after %d", herr.StatusCode, herr.Attempts, tt.status, tt.attempts)
+			}
+			for _, k := range kinds {
+				if got, want := errors.Is(err, k), k == tt.kind; got != want {
+					t.Errorf("errors.Is(err, %v) = %v, want %v", k, got, want)
+				}
+			}
+			if got := srv.Calls()[fakesteam.GetPlayerAchievements]; got != tt.attempts {
+				t.Errorf("server saw %d calls, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestNoStatsIsEmpty(t *testing.T) {
+	// The fake answers 400 "Requested app has no stats" for apps without a fixture.
+	c, srv := newFakeClient(t)
+	ach, err := c.GetPlayerAchievements(context.Background(), testSteamID, 999)
+	if err != nil || ach == nil || len(ach) != 0 {
+		t.Errorf("got %v, %v; want an empty list", ach, err)
+	}
+	if got := srv.Calls()[fakesteam.GetPlayerAchievements]; got != 1 {
+		t.Errorf("server saw %d calls, want 1", got)
+	}
+}
+
+func TestRetryServerErrorThenSucceed(t *testing.T) {
+	c, srv := newFakeClient(t, fakesteam.Rule{Endpoint: fakesteam.GetOwnedGames, Status: 503, Times: 2})
+	games, err := c.GetOwnedGames(context.Background(), testSteamID)
+	if err != nil {
+		t.Fatalf("err = %v, want success on the third attempt", err)
+	}
+	if len(games) == 0 {
+		t.Error("no games")
+	}
+	if got := srv.Calls()[fakesteam.GetOwnedGames]; got != 3 {
+		t.Errorf("server saw %d calls, want 3", got)
+	}
+}
+
+func TestRetryAfterHonoured(t *testing.T) {
+	c, srv := newFakeClient(t, fakesteam.Rule{Endpoint: fakesteam.GetOwnedGames, Status: 429, RetryAfter: 1, Times: 1})
+	start := time.Now()
+	if _, err := c.GetOwnedGames(context.Background(), testSteamID); err != nil {
+		t.Fatal(err)
+	}
+	if el := time.Since(start); el < time.Second {
+		t.Errorf("retried after %s, want at least the 1s Retry-After", el)
+	}
+	if got := srv.Calls()[fakesteam.GetOwnedGames]; got != 2 {
+		t.Errorf("server saw %d calls, want 2", got)
+	}
+}
+
+func TestRetryAfterOverMaxWait(t *testing.T) {
+	c, srv := newFakeClient(t, fakesteam.Rule{Endpoint: fakesteam.GetOwnedGames, Status: 429, RetryAfter: 120})
+	start := time.Now()
+	_, err := c.GetOwnedGames(context.Background(), testSteamID)
+	var herr *HTTPError
+	if !errors.As(err, &herr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want rate limited *HTTPError", err)
+	}
+	if herr.RetryAfter != 120*time.Second || herr.Attempts != 1 {
+		t.Errorf("RetryAfter %s after %d attempts, want 2m0s after 1", herr.RetryAfter, herr.Attempts)
+	}
+	if el := time.Since(start); el > 500*time.Millisecond {
+		t.Errorf("took %s; a Retry-After over MaxWait must return at once", el)
+	}
+	if got := srv.Calls()[fakesteam.GetOwnedGames]; got != 1 {
+		t.Errorf("server saw %d calls, want 1", got)
+	}
+}
+
+func TestPrivateGameDetails(t *testing.T) {
+	c, _ := newFakeClient(t, fakesteam.Rule{Endpoint: fakesteam.GetOwnedGames, Private: true})
+	if _, err := c.GetOwnedGames(context.Background(), testSteamID); !errors.Is(err, ErrPrivate) {
+		t.Errorf("err = %v, want ErrPrivate", err)
+	}
+}
+
+func TestContextCancelStopsRetries(t *testing.T) {
+	c, srv := newFakeClient(t, fakesteam.Rule{Endpoint: fakesteam.GetOwnedGames, Status: 429, RetryAfter: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetOwnedGames(ctx, testSteamID); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if got := srv.Calls()[fakesteam.GetOwnedGames]; got != 1 {
+		t.Errorf("server saw %d calls, want 1", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"0", 0},
+		{"-3", 0},
+		{"soon", 0},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.in, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package steamapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Error kinds. Match with errors.Is; use errors.As(*HTTPError) for status/Retry-After.
+var (
+	ErrRateLimited  = errors.New("steam: rate limited")
+	ErrUnauthorized = errors.New("steam: unauthorized (check STEAM_API_KEY)")
+	ErrNotFound     = errors.New("steam: not found")
+	ErrServer       = errors.New("steam: server error")
+	ErrPrivate      = errors.New("steam: profile or game details are private")
+	ErrNoStats      = errors.New("steam: app has no stats for this player")
+)
+
+// HTTPError is returned when Steam answers with a non-2xx status
+// (after retries, for retryable statuses).
+type HTTPError struct {
+	StatusCode int
+	Endpoint   string        // URL path, without the query (which carries the key)
+	RetryAfter time.Duration // parsed Retry-After header, 0 if absent
+	Attempts   int
+	kind       error
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("steam http %d %s", e.StatusCode, e.Endpoint)
+	if e.Attempts > 1 {
+		msg += fmt.Sprintf(" (after %d attempts)", e.Attempts)
+	}
+	if e.kind != nil {
+		msg = e.kind.Error() + ": " + msg
+	}
+	return msg
+}
+
+// Unwrap exposes the error kind (ErrRateLimited, ErrServer, ...) to errors.Is.
+func (e *HTTPError) Unwrap() error { return e.kind }
+
+// Kind names the class of a Steam call failure for reports and JSON:
+// rate_limited, unauthorized, not_found, server, private, budget, timeout,
+// canceled, http (another non-2xx status) or other (network, decoding, ...).
+func Kind(err error) string {
+	var herr *HTTPError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrServer):
+		return "server"
+	case errors.Is(err, ErrPrivate):
+		return "private"
+	case errors.Is(err, ErrBudgetExhausted):
+		return "budget"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &herr):
+		return "http"
+	default:
+		return "other"
+	}
+}
+
+// newHTTPError classifies resp. It may read (a bounded prefix of) the body.
+func newHTTPError(resp *http.Response, attempts int) *HTTPError {
+	e := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Endpoint:   resp.Request.URL.Path,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		Attempts:   attempts,
+		kind:       kindForStatus(resp.StatusCode),
+	}
+	// GetPlayerAchievements answers 403 for private profiles too, and 400 for games
+	// without player stats; only the body tells them apart.
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		body = bytes.ToLower(body)
+		switch {
+		case resp.StatusCode == http.StatusForbidden && bytes.Contains(body, []byte("not public")):
+			e.kind = ErrPrivate
+		case resp.StatusCode == http.StatusBadRequest && bytes.Contains(body, []byte("has no stats")):
+			e.kind = ErrNoStats
+		}
+	}
+	return e
+}
+
+func kindForStatus(code int) error {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ErrUnauthorized
+	case code == http.StatusNotFound:
+		return ErrNotFound
+	case code >= 500:
+		return ErrServer
+	default:
+		return nil
+	}
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// parseRetryAfter accepts delta-seconds or an HTTP date. Returns 0 if absent/invalid.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if n, err := strconv.Atoi(v); err == nil {
+		if n < 0 {
+			return 0
+		}
+		return time.Duration(n) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
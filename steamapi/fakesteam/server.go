@@ -0,0 +1,365 @@
+// Package fakesteam is a local stand-in for api.steampowered.com.
+//
+// It answers the endpoints steamapi.Client uses from JSON fixture files and can
+// be scripted with a Scenario (DLC drops, private profiles, HTTP 429s, ...) so the
+// refresh pipeline can be exercised end to end without network access.
+//
+// Fixture layout (relative to the fixture dir):
+//
+//	owned/<steamid>.json                  GetOwnedGames response
+//	schema/<appid>.json                   GetSchemaForGame response
+//	achievements/<steamid>/<appid>.json   GetPlayerAchievements response
+//	rarity/<appid>.json                   GetGlobalAchievementPercentagesForApp response
+//	players/<steamid>.json                one GetPlayerSummaries player object
+//	vanity.json                           {"<vanity name>": "<steamid64>", ...}
+//	icons/<appid>/<file>                  icon served at /steamcommunity/public/images/apps/<appid>/<file>
+//
+// "{{base}}" in a JSON fixture is replaced with this server's own URL, so icon URLs
+// in schema fixtures point back here. Icons without a fixture file get a generated
+// placeholder image.
+package fakesteam
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Endpoint names used in scenario rules.
+const (
+	GetOwnedGames         = "GetOwnedGames"
+	GetSchemaForGame      = "GetSchemaForGame"
+	GetPlayerAchievements = "GetPlayerAchievements"
+	ResolveVanityURL      = "ResolveVanityURL"
+	GetPlayerSummaries    = "GetPlayerSummaries"
+	GetGlobalPercentages  = "GetGlobalAchievementPercentagesForApp"
+	Icon                  = "Icon"
+)
+
+// iconPrefix is where Steam's media host serves app and achievement icons.
+const iconPrefix = "/steamcommunity/public/images/apps/"
+
+// Scenario is an ordered list of rules; the first applicable rule wins.
+type Scenario struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule overrides the fixture response for matching calls.
+// Empty Endpoint/SteamID and zero AppID match anything.
+type Rule struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	SteamID  string `json:"steamid,omitempty"`
+	AppID    int64  `json:"appid,omitempty"`
+
+	After int `json:"after,omitempty"` // let the first N matching calls through untouched
+	Times int `json:"times,omitempty"` // then apply N times (0 = forever)
+
+	Status     int    `json:"status,omitempty"`      // reply with this HTTP status and an empty body
+	RetryAfter int    `json:"retry_after,omitempty"` // seconds, sent as Retry-After
+	Fixture    string `json:"fixture,omitempty"`     // serve this file (relative to the fixture dir) instead
+	Private    bool   `json:"private,omitempty"`     // behave like Steam does for a private profile
+}
+
+// Server serves fixtures over HTTP. It is safe for concurrent use.
+type Server struct {
+	dir string
+	mux *http.ServeMux
+
+	mu       sync.Mutex
+	scenario Scenario
+	hits     []int // per-rule matching call counts
+	calls    map[string]int
+}
+
+// New returns a server reading fixtures from dir.
+func New(dir string) (*Server, error) {
+	if fi, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("fixtures dir: %w", err)
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("fixtures dir: %s is not a directory", dir)
+	}
+	s := &Server{dir: dir, calls: map[string]int{}}
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/IPlayerService/GetOwnedGames/v1/", s.ownedGames)
+	s.mux.HandleFunc("/ISteamUserStats/GetSchemaForGame/v2/", s.schemaForGame)
+	s.mux.HandleFunc("/ISteamUserStats/GetPlayerAchievements/v1/", s.playerAchievements)
+	s.mux.HandleFunc("/ISteamUser/ResolveVanityURL/v1/", s.resolveVanityURL)
+	s.mux.HandleFunc("/ISteamUser/GetPlayerSummaries/v2/", s.playerSummaries)
+	s.mux.HandleFunc("/ISteamUserStats/GetGlobalAchievementPercentagesForApp/v2/", s.globalPercentages)
+	s.mux.HandleFunc(iconPrefix, s.icon)
+	s.mux.HandleFunc("/_fake/scenario", s.scenarioHandler)
+	s.mux.HandleFunc("/_fake/calls", s.callsHandler)
+	return s, nil
+}
+
+// SetScenario replaces the active scenario and resets rule counters.
+func (s *Server) SetScenario(sc Scenario) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scenario = sc
+	s.hits = make([]int, len(sc.Rules))
+}
+
+// LoadScenario reads a Scenario from a JSON file and activates it.
+func (s *Server) LoadScenario(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var sc Scenario
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return fmt.Errorf("parse scenario %s: %w", path, err)
+	}
+	s.SetScenario(sc)
+	return nil
+}
+
+// Calls returns how many times each endpoint has been hit.
+func (s *Server) Calls() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int, len(s.calls))
+	for k, v := range s.calls {
+		out[k] = v
+	}
+	return out
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ------------ Steam endpoints ------------
+
+func (s *Server) ownedGames(w http.ResponseWriter, r *http.Request) {
+	steamid := r.URL.Query().Get("steamid")
+	rule := s.match(GetOwnedGames, steamid, 0)
+	if s.applyRule(w, r, rule) {
+		return
+	}
+	if rule != nil && rule.Private {
+		// Steam returns an empty response object when game details are private.
+		writeJSON(w, http.StatusOK, map[string]any{"response": map[string]any{}})
+		return
+	}
+	s.serveFixture(w, r, filepath.Join("owned", steamid+".json"), map[string]any{"response": map[string]any{}})
+}
+
+func (s *Server) schemaForGame(w http.ResponseWriter, r *http.Request) {
+	appid, _ := strconv.ParseInt(r.URL.Query().Get("appid"), 10, 64)
+	rule := s.match(GetSchemaForGame, "", appid)
+	if s.applyRule(w, r, rule) {
+		return
+	}
+	// Games without stats come back as an empty game object.
+	s.serveFixture(w, r, filepath.Join("schema", strconv.FormatInt(appid, 10)+".json"), map[string]any{"game": map[string]any{}})
+}
+
+func (s *Server) playerAchievements(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	steamid := q.Get("steamid")
+	appid, _ := strconv.ParseInt(q.Get("appid"), 10, 64)
+	rule := s.match(GetPlayerAchievements, steamid, appid)
+	if s.applyRule(w, r, rule) {
+		return
+	}
+	if rule != nil && rule.Private {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"playerstats": map[string]any{"error": "Profile is not public", "success": false},
+		})
+		return
+	}
+	name := filepath.Join("achievements", steamid, strconv.FormatInt(appid, 10)+".json")
+	if _, err := os.Stat(filepath.Join(s.dir, name)); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"playerstats": map[string]any{"error": "Requested app has no stats", "success": false},
+		})
+		return
+	}
+	s.serveFixture(w, r, name, nil)
+}
+
+func (s *Server) resolveVanityURL(w http.ResponseWriter, r *http.Request) {
+	vanity := r.URL.Query().Get("vanityurl")
+	if s.applyRule(w, r, s.match(ResolveVanityURL, "", 0)) {
+		return
+	}
+	var names map[string]string
+	if b, err := os.ReadFile(filepath.Join(s.dir, "vanity.json")); err == nil {
+		_ = json.Unmarshal(b, &names)
+	}
+	if id, ok := names[vanity]; ok {
+		writeJSON(w, http.StatusOK, map[string]any{"response": map[string]any{"steamid": id, "success": 1}})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"response": map[string]any{"success": 42, "message": "No match"}})
+}
+
+func (s *Server) playerSummaries(w http.ResponseWriter, r *http.Request) {
+	steamid := r.URL.Query().Get("steamids")
+	rule := s.match(GetPlayerSummaries, steamid, 0)
+	if s.applyRule(w, r, rule) {
+		return
+	}
+	players := []map[string]any{}
+	if b, err := os.ReadFile(filepath.Join(s.dir, "players", steamid+".json")); err == nil {
+		var p map[string]any
+		if err := json.Unmarshal(b, &p); err != nil {
+			log.Printf("fakesteam: players/%s.json: %v", steamid, err)
+		} else {
+			if rule != nil && rule.Private {
+				p["communityvisibilitystate"] = 1
+			}
+			players = append(players, p)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"response": map[string]any{"players": players}})
+}
+
+func (s *Server) globalPercentages(w http.ResponseWriter, r *http.Request) {
+	appid, _ := strconv.ParseInt(r.URL.Query().Get("gameid"), 10, 64)
+	if s.applyRule(w, r, s.match(GetGlobalPercentages, "", appid)) {
+		return
+	}
+	s.serveFixture(w, r, filepath.Join("rarity", strconv.FormatInt(appid, 10)+".json"),
+		map[string]any{"achievementpercentages": map[string]any{"achievements": []any{}}})
+}
+
+// icon serves icons/<appid>/<file> from the fixtures, or a placeholder generated
+// from the path so every icon URL resolves (and different URLs get different bytes).
+func (s *Server) icon(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, iconPrefix), "/")
+	if len(parts) != 2 || parts[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	appid, _ := strconv.ParseInt(parts[0], 10, 64)
+	if s.applyRule(w, r, s.match(Icon, "", appid)) {
+		return
+	}
+	if b, err := os.ReadFile(filepath.Join(s.dir, "icons", parts[0], filepath.Base(parts[1]))); err == nil {
+		w.Header().Set("Content-Type", http.DetectContentType(b))
+		_, _ = w.Write(b)
+		return
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(r.URL.Path))
+	sum := h.Sum32()
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: uint8(sum), G: uint8(sum >> 8), B: uint8(sum >> 16), A: 255}}, image.Point{}, draw.Src)
+	w.Header().Set("Content-Type", "image/jpeg")
+	_ = jpeg.Encode(w, img, nil)
+}
+
+// ------------ control endpoints ------------
+
+// PUT /_fake/scenario swaps the active scenario; GET returns it.
+func (s *Server) scenarioHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		sc := s.scenario
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, sc)
+	case http.MethodPut, http.MethodPost:
+		var sc Scenario
+		if err := json.NewDecoder(r.Body).Decode(&sc); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		s.SetScenario(sc)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /_fake/calls returns per-endpoint hit counts.
+func (s *Server) callsHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Calls())
+}
+
+// ------------ internals ------------
+
+// match counts the call and returns the first rule that applies to it (nil if none).
+func (s *Server) match(endpoint, steamid string, appid int64) *Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[endpoint]++
+
+	var hit *Rule
+	for i := range s.scenario.Rules {
+		rl := &s.scenario.Rules[i]
+		if rl.Endpoint != "" && rl.Endpoint != endpoint {
+			continue
+		}
+		if rl.SteamID != "" && steamid != "" && rl.SteamID != steamid {
+			continue
+		}
+		if rl.AppID != 0 && appid != 0 && rl.AppID != appid {
+			continue
+		}
+		s.hits[i]++
+		n := s.hits[i]
+		if hit == nil && n > rl.After && (rl.Times == 0 || n <= rl.After+rl.Times) {
+			r := *rl
+			hit = &r
+		}
+	}
+	return hit
+}
+
+// applyRule writes a status or fixture override. It returns true if the response was written.
+func (s *Server) applyRule(w http.ResponseWriter, r *http.Request, rule *Rule) bool {
+	if rule == nil {
+		return false
+	}
+	if rule.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(rule.RetryAfter))
+	}
+	if rule.Status != 0 {
+		w.WriteHeader(rule.Status)
+		return true
+	}
+	if rule.Fixture != "" {
+		s.serveFixture(w, r, rule.Fixture, nil)
+		return true
+	}
+	return false
+}
+
+// serveFixture writes dir/name as JSON. If the file is missing, fallback is sent
+// (or 404 when fallback is nil).
+func (s *Server) serveFixture(w http.ResponseWriter, r *http.Request, name string, fallback any) {
+	b, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) && fallback != nil {
+			writeJSON(w, http.StatusOK, fallback)
+			return
+		}
+		log.Printf("fakesteam: fixture %s: %v", name, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	b = bytes.ReplaceAll(b, []byte("{{base}}"), []byte("http://"+r.Host))
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
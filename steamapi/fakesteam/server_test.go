@@ -0,0 +1,257 @@
+package fakesteam
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	testSteamID = "76561197960287930"
+	otherID     = "76561197960287931"
+)
+
+func newTestServer(t *testing.T, sc Scenario) (*Server, *httptest.Server) {
+	t.Helper()
+	s, err := New("../../testdata/fakesteam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetScenario(sc)
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func get(t *testing.T, ts *httptest.Server, path string) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.Get(ts.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(b)
+}
+
+func ownedPath(steamid string) string {
+	return "/IPlayerService/GetOwnedGames/v1/?steamid=" + steamid
+}
+
+func achievementsPath(steamid, appid string) string {
+	return "/ISteamUserStats/GetPlayerAchievements/v1/?steamid=" + steamid + "&appid=" + appid
+}
+
+// statuses returns the status of n consecutive calls to path.
+func statuses(t *testing.T, ts *httptest.Server, path string, n int) []int {
+	t.Helper()
+	out := make([]int, n)
+	for i := range out {
+		resp, _ := get(t, ts, path)
+		out[i] = resp.StatusCode
+	}
+	return out
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRuleAfterAndTimes(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		want []int
+	}{
+		{"forever", Rule{Endpoint: GetOwnedGames, Status: 500}, []int{500, 500, 500, 500}},
+		{"times", Rule{Endpoint: GetOwnedGames, Status: 500, Times: 2}, []int{500, 500, 200, 200}},
+		{"after", Rule{Endpoint: GetOwnedGames, Status: 500, After: 1}, []int{200, 500, 500, 500}},
+		{"after and times", Rule{Endpoint: GetOwnedGames, Status: 500, After: 1, Times: 2}, []int{200, 500, 500, 200}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ts := newTestServer(t, Scenario{Rules: []Rule{tt.rule}})
+			if got := statuses(t, ts, ownedPath(testSteamID), len(tt.want)); !equalInts(got, tt.want) {
+				t.Errorf("statuses = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleMatching(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		path string
+		want int
+	}{
+		{"endpoint matches", Rule{Endpoint: GetOwnedGames, Status: 503}, ownedPath(testSteamID), 503},
+		{"other endpoint", Rule{Endpoint: GetSchemaForGame, Status: 503}, ownedPath(testSteamID), 200},
+		{"empty endpoint matches all", Rule{Status: 503}, ownedPath(testSteamID), 503},
+		{"steamid matches", Rule{SteamID: testSteamID, Status: 503}, ownedPath(testSteamID), 503},
+		{"other steamid", Rule{SteamID: otherID, Status: 503}, ownedPath(testSteamID), 200},
+		{"appid matches", Rule{AppID: 620, Status: 503}, achievementsPath(testSteamID, "620"), 503},
+		{"other appid", Rule{AppID: 440, Status: 503}, achievementsPath(testSteamID, "620"), 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ts := newTestServer(t, Scenario{Rules: []Rule{tt.rule}})
+			if resp, _ := get(t, ts, tt.path); resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstApplicableRuleWins(t *testing.T) {
+	_, ts := newTestServer(t, Scenario{Rules: []Rule{
+		{Endpoint: GetOwnedGames, Status: 429, Times: 1},
+		{Endpoint: GetOwnedGames, Status: 503},
+	}})
+	// The second rule counts the first call too, so it is applicable from call two on.
+	if got, want := statuses(t, ts, ownedPath(testSteamID), 3), []int{429, 503, 503}; !equalInts(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+}
+
+func TestRuleRetryAfter(t *testing.T) {
+	_, ts := newTestServer(t, Scenario{Rules: []Rule{{Endpoint: GetOwnedGames, Status: 429, RetryAfter: 7}}})
+	resp, body := get(t, ts, ownedPath(testSteamID))
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "7" {
+		t.Errorf("Retry-After = %q, want 7", got)
+	}
+	if body != "" {
+		t.Errorf("body = %q, want empty", body)
+	}
+}
+
+func TestRuleFixture(t *testing.T) {
+	_, ts := newTestServer(t, Scenario{Rules: []Rule{{Endpoint: GetSchemaForGame, AppID: 440, Fixture: "schema/440_dlc.json"}}})
+	_, dlc := get(t, ts, "/ISteamUserStats/GetSchemaForGame/v2/?appid=440")
+	_, plain := get(t, ts, "/ISteamUserStats/GetSchemaForGame/v2/?appid=620")
+	if !strings.Contains(dlc, `"achievements"`) {
+		t.Fatalf("fixture body = %q", dlc)
+	}
+	if dlc == plain {
+		t.Error("fixture override served the same body as another app")
+	}
+	if strings.Contains(dlc, "{{base}}") {
+		t.Error("{{base}} not replaced in fixture")
+	}
+}
+
+func TestRulePrivate(t *testing.T) {
+	t.Run("owned games", func(t *testing.T) {
+		_, ts := newTestServer(t, Scenario{Rules: []Rule{{Endpoint: GetOwnedGames, Private: true}}})
+		resp, body := get(t, ts, ownedPath(testSteamID))
+		var v struct {
+			Response map[string]any `json:"response"`
+		}
+		if err := json.Unmarshal([]byte(body), &v); err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || len(v.Response) != 0 {
+			t.Errorf("got %d %s, want 200 with an empty response", resp.StatusCode, body)
+		}
+	})
+	t.Run("achievements", func(t *testing.T) {
+		_, ts := newTestServer(t, Scenario{Rules: []Rule{{Endpoint: GetPlayerAchievements, Private: true}}})
+		resp, body := get(t, ts, achievementsPath(testSteamID, "620"))
+		if resp.StatusCode != http.StatusForbidden || !strings.Contains(body, "not public") {
+			t.Errorf("got %d %s, want 403 not public", resp.StatusCode, body)
+		}
+	})
+	t.Run("player summary", func(t *testing.T) {
+		_, ts := newTestServer(t, Scenario{Rules: []Rule{{Endpoint: GetPlayerSummaries, Private: true}}})
+		_, body := get(t, ts, "/ISteamUser/GetPlayerSummaries/v2/?steamids="+testSteamID)
+		var v struct {
+			Response struct {
+				Players []struct {
+					Visibility int `json:"communityvisibilitystate"`
+				} `json:"players"`
+			} `json:"response"`
+		}
+		if err := json.Unmarshal([]byte(body), &v); err != nil {
+			t.Fatal(err)
+		}
+		if len(v.Response.Players) != 1 || v.Response.Players[0].Visibility != 1 {
+			t.Errorf("players = %+v, want one with visibility 1", v.Response.Players)
+		}
+	})
+}
+
+func TestScenarioEndpoint(t *testing.T) {
+	s, ts := newTestServer(t, Scenario{})
+
+	body := `{"rules":[{"endpoint":"GetOwnedGames","status":502,"times":1}]}`
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/_fake/scenario", strings.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PUT status = %d, want 204", resp.StatusCode)
+	}
+
+	_, got := get(t, ts, "/_fake/scenario")
+	var sc Scenario
+	if err := json.Unmarshal([]byte(got), &sc); err != nil {
+		t.Fatal(err)
+	}
+	if len(sc.Rules) != 1 || sc.Rules[0].Status != 502 {
+		t.Errorf("GET scenario = %+v", sc)
+	}
+	if got, want := statuses(t, ts, ownedPath(testSteamID), 2), []int{502, 200}; !equalInts(got, want) {
+		t.Errorf("statuses = %v, want %v", got, want)
+	}
+
+	// Swapping the scenario resets rule counters.
+	s.SetScenario(sc)
+	if resp, _ := get(t, ts, ownedPath(testSteamID)); resp.StatusCode != 502 {
+		t.Errorf("after reset status = %d, want 502", resp.StatusCode)
+	}
+
+	req, _ = http.NewRequest(http.MethodPut, ts.URL+"/_fake/scenario", strings.NewReader("{"))
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad PUT status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestCallsEndpoint(t *testing.T) {
+	_, ts := newTestServer(t, Scenario{})
+	get(t, ts, ownedPath(testSteamID))
+	get(t, ts, ownedPath(testSteamID))
+	get(t, ts, achievementsPath(testSteamID, "620"))
+
+	_, body := get(t, ts, "/_fake/calls")
+	var calls map[string]int
+	if err := json.Unmarshal([]byte(body), &calls); err != nil {
+		t.Fatal(err)
+	}
+	if calls[GetOwnedGames] != 2 || calls[GetPlayerAchievements] != 1 {
+		t.Errorf("calls = %v", calls)
+	}
+}
@@ -0,0 +1,156 @@
+package steamapi
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/config"
+)
+
+// ErrBudgetExhausted is returned when the daily call budget is spent.
+var ErrBudgetExhausted = errors.New("steam: daily call budget exhausted")
+
+// Limiter is a token bucket plus a per-UTC-day call budget.
+// Waiters reserve tokens in arrival order, so concurrent refreshes share it fairly.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64 // may go negative: outstanding reservations
+	last   time.Time
+
+	budget  int
+	used    int
+	day     time.Time // UTC midnight the current budget window started
+	waiting int
+	now     func() time.Time
+	sleep   func(context.Context, time.Duration) error
+}
+
+// LimiterUsage is a point-in-time view of the limiter, for operators.
+type LimiterUsage struct {
+	RequestsPerSecond float64   `json:"requests_per_second"`
+	Burst             int       `json:"burst"`
+	DailyBudget       int       `json:"daily_budget"`
+	UsedToday         int       `json:"used_today"`
+	RemainingToday    int       `json:"remaining_today"`
+	UsedPct           float64   `json:"used_pct"`
+	ResetsAt          time.Time `json:"resets_at"`
+	Waiting           int       `json:"waiting"`
+}
+
+// NewLimiter returns a limiter allowing rps calls per second (bursting to burst)
+// and at most budget calls per UTC day (budget <= 0 means unlimited).
+func NewLimiter(rps float64, burst, budget int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	now := time.Now
+	t := now().UTC()
+	return &Limiter{
+		rate:   rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   t,
+		budget: budget,
+		day:    utcMidnight(t),
+		now:    now,
+		sleep:  sleepCtx,
+	}
+}
+
+var (
+	sharedOnce    sync.Once
+	sharedLimiter *Limiter
+)
+
+// SharedLimiter is the process-wide limiter every Client from New draws from.
+// Configured via config.SteamRequestsPerSecond and config.SteamDailyBudget.
+func SharedLimiter() *Limiter {
+	sharedOnce.Do(func() {
+		rps := config.SteamRequestsPerSecond()
+		sharedLimiter = NewLimiter(rps, int(math.Ceil(rps)), config.SteamDailyBudget())
+	})
+	return sharedLimiter
+}
+
+// Wait blocks until a call may be made, or returns ErrBudgetExhausted / ctx.Err().
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := l.now().UTC()
+	l.rollDay(now)
+	if l.budget > 0 && l.used >= l.budget {
+		l.mu.Unlock()
+		return ErrBudgetExhausted
+	}
+	l.refill(now)
+	l.tokens--
+	l.used++
+	var delay time.Duration
+	if l.tokens < 0 && l.rate > 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	if delay == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	l.waiting++
+	l.mu.Unlock()
+
+	err := l.sleep(ctx, delay)
+
+	l.mu.Lock()
+	l.waiting--
+	if err != nil {
+		// Hand the reservation back so later waiters aren't penalised.
+		l.tokens++
+		l.used--
+	}
+	l.mu.Unlock()
+	return err
+}
+
+// Usage reports current budget consumption.
+func (l *Limiter) Usage() LimiterUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rollDay(l.now().UTC())
+	u := LimiterUsage{
+		RequestsPerSecond: l.rate,
+		Burst:             int(l.burst),
+		DailyBudget:       l.budget,
+		UsedToday:         l.used,
+		ResetsAt:          l.day.Add(24 * time.Hour),
+		Waiting:           l.waiting,
+	}
+	if l.budget > 0 {
+		u.RemainingToday = max(l.budget-l.used, 0)
+		u.UsedPct = float64(l.used) / float64(l.budget) * 100
+	}
+	return u
+}
+
+func (l *Limiter) refill(now time.Time) {
+	if el := now.Sub(l.last).Seconds(); el > 0 {
+		l.tokens = math.Min(l.burst, l.tokens+el*l.rate)
+	}
+	l.last = now
+}
+
+func (l *Limiter) rollDay(now time.Time) {
+	if d := utcMidnight(now); d.After(l.day) {
+		l.day = d
+		l.used = 0
+	}
+}
+
+func utcMidnight(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,189 @@
+package steamapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/James-Wolfley/steam-achievement-tracker/steamapi/fakesteam"
+)
+
+// fakeClock drives a Limiter without real sleeping. Sleeps are recorded and,
+// with advance set, move the clock forward.
+type fakeClock struct {
+	mu      sync.Mutex
+	t       time.Time
+	advance bool
+	slept   []time.Duration
+	fail    error // returned by sleep instead of sleeping
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func (c *fakeClock) sleep(_ context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slept = append(c.slept, d)
+	if c.fail != nil {
+		return c.fail
+	}
+	if c.advance {
+		c.t = c.t.Add(d)
+	}
+	return nil
+}
+
+func newTestLimiter(rps float64, burst, budget int, clock *fakeClock) *Limiter {
+	l := NewLimiter(rps, burst, budget)
+	l.now, l.sleep = clock.now, clock.sleep
+	l.last, l.day = clock.t, utcMidnight(clock.t)
+	return l
+}
+
+func TestLimiterPacing(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	l := newTestLimiter(2, 1, 0, clock)
+	ctx := context.Background()
+
+	// The burst token goes at once; later callers queue behind each other.
+	for i := 0; i < 4; i++ {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []time.Duration{500 * time.Millisecond, time.Second, 1500 * time.Millisecond}
+	if len(clock.slept) != len(want) {
+		t.Fatalf("slept %v, want %v", clock.slept, want)
+	}
+	for i := range want {
+		if clock.slept[i] != want[i] {
+			t.Errorf("sleep %d = %s, want %s", i, clock.slept[i], want[i])
+		}
+	}
+}
+
+func TestLimiterSteadyRate(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), advance: true}
+	l := newTestLimiter(4, 2, 0, clock)
+	start := clock.now()
+	for i := 0; i < 10; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Two burst tokens, then one call every 250ms.
+	if got, want := clock.now().Sub(start), 2*time.Second; got != want {
+		t.Errorf("10 calls took %s of clock time, want %s", got, want)
+	}
+}
+
+func TestLimiterRefillCapsAtBurst(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	l := newTestLimiter(1, 3, 0, clock)
+	clock.add(time.Hour) // idle far longer than it takes to refill
+	for i := 0; i < 4; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(clock.slept) != 1 || clock.slept[0] != time.Second {
+		t.Errorf("slept %v, want only the 4th call to wait 1s", clock.slept)
+	}
+}
+
+func TestLimiterDailyBudget(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)}
+	l := newTestLimiter(1000, 1000, 3, clock)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	if err := l.Wait(ctx); !errors.Is(err, ErrBudgetExhausted) {
+		t.Fatalf("4th call err = %v, want ErrBudgetExhausted", err)
+	}
+	u := l.Usage()
+	if u.UsedToday != 3 || u.RemainingToday != 0 || u.UsedPct != 100 {
+		t.Errorf("usage = %+v", u)
+	}
+	if want := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC); !u.ResetsAt.Equal(want) {
+		t.Errorf("ResetsAt = %s, want %s", u.ResetsAt, want)
+	}
+
+	// The budget resets at UTC midnight.
+	clock.add(time.Minute)
+	if err := l.Wait(ctx); err != nil {
+		t.Fatalf("after midnight: %v", err)
+	}
+	if u := l.Usage(); u.UsedToday != 1 || u.RemainingToday != 2 {
+		t.Errorf("usage after reset = %+v", u)
+	}
+}
+
+func TestLimiterCancelReturnsReservation(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), fail: context.Canceled}
+	l := newTestLimiter(1, 1, 10, clock)
+	ctx := context.Background()
+	if err := l.Wait(ctx); err != nil { // burst token, no sleep
+		t.Fatal(err)
+	}
+	if err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if u := l.Usage(); u.UsedToday != 1 || u.Waiting != 0 {
+		t.Errorf("usage = %+v, want the cancelled call handed back", u)
+	}
+	// The handed-back token means the next caller waits 1s, not 2s.
+	clock.fail = nil
+	clock.slept = nil
+	if err := l.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(clock.slept) != 1 || clock.slept[0] != time.Second {
+		t.Errorf("slept %v, want [1s]", clock.slept)
+	}
+}
+
+func TestRetriesCountTowardBudget(t *testing.T) {
+	clock := &fakeClock{t: time.Now().UTC()}
+
+	t.Run("every attempt is counted", func(t *testing.T) {
+		l := newTestLimiter(1000, 1000, 10, clock)
+		c, srv := newFakeClient(t, fakesteam.Rule{Endpoint: fakesteam.GetOwnedGames, Status: 503})
+		c = c.WithLimiter(l)
+		if _, err := c.GetOwnedGames(context.Background(), testSteamID); !errors.Is(err, ErrServer) {
+			t.Fatalf("err = %v, want ErrServer", err)
+		}
+		if got := l.Usage().UsedToday; got != fastRetry.MaxAttempts {
+			t.Errorf("used %d, want %d (one per attempt)", got, fastRetry.MaxAttempts)
+		}
+		if got := srv.Calls()[fakesteam.GetOwnedGames]; got != fastRetry.MaxAttempts {
+			t.Errorf("server saw %d calls, want %d", got, fastRetry.MaxAttempts)
+		}
+	})
+
+	t.Run("retries stop when the budget runs out", func(t *testing.T) {
+		l := newTestLimiter(1000, 1000, 2, clock)
+		c, srv := newFakeClient(t, fakesteam.Rule{Endpoint: fakesteam.GetOwnedGames, Status: 503})
+		c = c.WithLimiter(l)
+		if _, err := c.GetOwnedGames(context.Background(), testSteamID); !errors.Is(err, ErrBudgetExhausted) {
+			t.Fatalf("err = %v, want ErrBudgetExhausted", err)
+		}
+		if got := srv.Calls()[fakesteam.GetOwnedGames]; got != 2 {
+			t.Errorf("server saw %d calls, want 2", got)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+// Package steamid turns user input (SteamID64, Steam2, Steam3, profile URLs,
+// vanity names) into a canonical SteamID64 string.
+package steamid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalid is wrapped by every parse failure; match with errors.Is.
+var ErrInvalid = errors.New("invalid steam id")
+
+// individualBase is the SteamID64 of account 0 in the public universe, individual type.
+const individualBase uint64 = 76561197960265728
+
+// Error explains why input was rejected, in words suitable for the end user.
+type Error struct {
+	Input  string
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %q: %s", ErrInvalid, e.Input, e.Reason)
+}
+
+func (e *Error) Unwrap() error { return ErrInvalid }
+
+// VanityResolver maps a custom profile name to a SteamID64 (steamapi.Client satisfies it).
+type VanityResolver interface {
+	ResolveVanityURL(ctx context.Context, vanity string) (string, error)
+}
+
+var (
+	steam2Re = regexp.MustCompile(`^STEAM_([01]):([01]):(\d+)$`)
+	steam3Re = regexp.MustCompile(`^\[?U:1:(\d+)\]?$`)
+	vanityRe = regexp.MustCompile(`^[A-Za-z0-9_-]{2,32}$`)
+)
+
+// Parse recognises every input form that doesn't need a network call.
+// Exactly one of id (canonical SteamID64) or vanity (a name to resolve) is set on success.
+func Parse(input string) (id, vanity string, err error) {
+	s := strings.TrimSpace(input)
+	if s == "" {
+		return "", "", &Error{Input: input, Reason: "enter a SteamID64, STEAM_0:X:Y, [U:1:X], profile URL or custom URL name"}
+	}
+
+	if isProfileURL(s) {
+		return parseURL(input, s)
+	}
+	if m := steam2Re.FindStringSubmatch(strings.ToUpper(s)); m != nil {
+		// The account number is Z*2+Y and must fit the 32-bit account field.
+		y, _ := strconv.ParseUint(m[2], 10, 64)
+		z, err := strconv.ParseUint(m[3], 10, 64)
+		if err != nil || z > math.MaxUint32>>1 || z*2+y == 0 {
+			return "", "", &Error{Input: input, Reason: "account number out of range"}
+		}
+		return format(individualBase + z*2 + y), "", nil
+	}
+	if m := steam3Re.FindStringSubmatch(strings.ToUpper(s)); m != nil {
+		acct, err := strconv.ParseUint(m[1], 10, 32)
+		if err != nil || acct == 0 {
+			return "", "", &Error{Input: input, Reason: "account number out of range"}
+		}
+		return format(individualBase + acct), "", nil
+	}
+	if len(s) == 17 && isDigits(s) {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || !isIndividual(n) {
+			return "", "", &Error{Input: input, Reason: "not a valid individual-account SteamID64 (these start with 7656119)"}
+		}
+		return s, "", nil
+	}
+	if vanityRe.MatchString(s) {
+		return "", s, nil
+	}
+	return "", "", &Error{Input: input, Reason: "not a SteamID, profile URL or custom URL name"}
+}
+
+// Resolve parses input and, for vanity names, asks r for the SteamID64.
+// r may be nil, in which case vanity input is rejected.
+func Resolve(ctx context.Context, input string, r VanityResolver) (string, error) {
+	id, vanity, err := Parse(input)
+	if err != nil {
+		return "", err
+	}
+	if vanity == "" {
+		return id, nil
+	}
+	if r == nil {
+		return "", &Error{Input: input, Reason: "custom URL names can't be looked up right now; use the SteamID64"}
+	}
+	resolved, err := r.ResolveVanityURL(ctx, vanity)
+	if err != nil {
+		return "", fmt.Errorf("resolve custom URL %q: %w", vanity, err)
+	}
+	id, _, err = Parse(resolved)
+	if err != nil || id == "" {
+		return "", fmt.Errorf("resolve custom URL %q: steam returned %q", vanity, resolved)
+	}
+	return id, nil
+}
+
+func isProfileURL(s string) bool {
+	l := strings.ToLower(s)
+	return strings.Contains(l, "steamcommunity.com/")
+}
+
+// parseURL handles steamcommunity.com/profiles/<id64> and /id/<vanity>.
+func parseURL(input, s string) (string, string, error) {
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", "", &Error{Input: input, Reason: "malformed URL"}
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if host != "steamcommunity.com" {
+		return "", "", &Error{Input: input, Reason: "only steamcommunity.com profile links are supported"}
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || parts[1] == "" {
+		return "", "", &Error{Input: input, Reason: "expected steamcommunity.com/profiles/<id> or /id/<name>"}
+	}
+	switch strings.ToLower(parts[0]) {
+	case "profiles":
+		id, vanity, err := Parse(parts[1])
+		if err != nil || vanity != "" {
+			return "", "", &Error{Input: input, Reason: "profile link doesn't contain a valid SteamID64"}
+		}
+		return id, "", nil
+	case "id":
+		if !vanityRe.MatchString(parts[1]) {
+			return "", "", &Error{Input: input, Reason: "custom URL name must be 2-32 letters, digits, _ or -"}
+		}
+		return "", parts[1], nil
+	default:
+		return "", "", &Error{Input: input, Reason: "expected steamcommunity.com/profiles/<id> or /id/<name>"}
+	}
+}
+
+// isIndividual checks universe=public(1), type=individual(1), instance=desktop(1), account>0.
+func isIndividual(n uint64) bool {
+	return n > individualBase && n>>32 == individualBase>>32
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func format(n uint64) string {
+	return strconv.FormatUint(n, 10)
+}
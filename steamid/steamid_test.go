@@ -0,0 +1,60 @@
+package steamid
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in     string
+		id     string
+		vanity string
+		bad    bool
+	}{
+		{in: "76561197960287930", id: "76561197960287930"},
+		{in: "STEAM_0:0:11101", id: "76561197960287930"},
+		{in: "steam_1:0:11101", id: "76561197960287930"},
+		{in: "STEAM_0:1:0", id: "76561197960265729"},
+		{in: "STEAM_0:1:2147483647", id: "76561202255233023"},
+		{in: "[U:1:22202]", id: "76561197960287930"},
+		{in: "U:1:22202", id: "76561197960287930"},
+		{in: "https://steamcommunity.com/profiles/76561197960287930/", id: "76561197960287930"},
+		{in: "steamcommunity.com/id/gabelogannewell", vanity: "gabelogannewell"},
+		{in: "gabelogannewell", vanity: "gabelogannewell"},
+
+		{in: "", bad: true},
+		{in: "STEAM_0:0:0", bad: true},           // account 0
+		{in: "STEAM_0:0:2147483648", bad: true},  // Z*2 overflows the 32-bit account
+		{in: "STEAM_0:1:4294967295", bad: true},  // Z itself is 32 bits, Z*2+Y is not
+		{in: "STEAM_0:0:99999999999", bad: true}, // doesn't fit 32 bits at all
+		{in: "[U:1:0]", bad: true},
+		{in: "[U:1:4294967296]", bad: true},
+		{in: "76561197960265728", bad: true}, // account 0 as ID64
+		{in: "12345678901234567", bad: true},
+		{in: "https://example.com/profiles/76561197960287930", bad: true},
+		{in: "a", bad: true},
+	}
+	for _, tt := range tests {
+		id, vanity, err := Parse(tt.in)
+		if tt.bad {
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("Parse(%q) = %q, %q, %v; want ErrInvalid", tt.in, id, vanity, err)
+			}
+			continue
+		}
+		if err != nil || id != tt.id || vanity != tt.vanity {
+			t.Errorf("Parse(%q) = %q, %q, %v; want %q, %q", tt.in, id, vanity, err, tt.id, tt.vanity)
+		}
+	}
+}
+
+func TestSteam2AccountRange(t *testing.T) {
+	for _, in := range []string{"STEAM_0:0:0", "STEAM_0:0:2147483648"} {
+		_, _, err := Parse(in)
+		var e *Error
+		if !errors.As(err, &e) || e.Reason != "account number out of range" {
+			t.Errorf("Parse(%q) err = %v, want account number out of range", in, err)
+		}
+	}
+}